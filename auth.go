@@ -0,0 +1,105 @@
+package strata
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthOpts defines the authentication options for the metrics endpoint.  When
+// both basic and bearer token authentication are configured, a request is
+// accepted if it satisfies either of them.
+type AuthOpts struct {
+	// BasicAuthUsers maps user names to bcrypt hashed passwords that are
+	// accepted using HTTP basic authentication.
+	BasicAuthUsers map[string]string
+	// BearerTokenFile is the path to a file containing the token that is
+	// accepted using bearer token authentication.  Leading and trailing
+	// whitespace is removed from the token.
+	BearerTokenFile string
+}
+
+type authenticator struct {
+	users map[string][]byte
+	token []byte
+}
+
+func newAuthenticator(opts *AuthOpts) (*authenticator, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	a := &authenticator{
+		users: make(map[string][]byte, len(opts.BasicAuthUsers)),
+	}
+
+	for user, hash := range opts.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash for user %s: %w", user, err)
+		}
+		a.users[user] = []byte(hash)
+	}
+
+	if opts.BearerTokenFile != "" {
+		token, err := os.ReadFile(opts.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read bearer token file: %w", err)
+		}
+
+		a.token = bytes.TrimSpace(token)
+		if len(a.token) == 0 {
+			return nil, fmt.Errorf("bearer token file %s is empty", opts.BearerTokenFile)
+		}
+	}
+
+	if len(a.users) == 0 && a.token == nil {
+		return nil, nil
+	}
+
+	return a, nil
+}
+
+// wrap returns a handler that only passes authenticated requests to next.  A
+// nil authenticator returns next unchanged.
+func (a *authenticator) wrap(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.authenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(a.users) > 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="strata"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+func (a *authenticator) authenticated(r *http.Request) bool {
+	if a.token != nil {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), a.token) == 1 {
+				return true
+			}
+		}
+	}
+
+	if len(a.users) > 0 {
+		if user, pass, ok := r.BasicAuth(); ok {
+			if hash, found := a.users[user]; found {
+				return bcrypt.CompareHashAndPassword(hash, []byte(pass)) == nil
+			}
+		}
+	}
+
+	return false
+}
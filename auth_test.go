@@ -0,0 +1,85 @@
+package strata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthBasic(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	auth, err := newAuthenticator(&AuthOpts{
+		BasicAuthUsers: map[string]string{"prometheus": string(hash)},
+	})
+	assert.NoError(t, err)
+	h := auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		user     string
+		pass     string
+		expected int
+	}{
+		{"prometheus", "secret", http.StatusOK},
+		{"prometheus", "wrong", http.StatusUnauthorized},
+		{"unknown", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, tt.expected, rec.Code, tt.user+":"+tt.pass)
+		if tt.expected == http.StatusUnauthorized {
+			assert.Equal(t, `Basic realm="strata"`, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestAuthBearer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(file, []byte("s3cr3t\n"), 0o600))
+
+	auth, err := newAuthenticator(&AuthOpts{BearerTokenFile: file})
+	assert.NoError(t, err)
+	h := auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for token, expected := range map[string]int{
+		"Bearer s3cr3t": http.StatusOK,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cr3t":        http.StatusUnauthorized,
+		"":              http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, expected, rec.Code, token)
+	}
+}
+
+func TestAuthOptsErrors(t *testing.T) {
+	_, err := newAuthenticator(&AuthOpts{BasicAuthUsers: map[string]string{"user": "plaintext"}})
+	assert.Error(t, err)
+
+	_, err = newAuthenticator(&AuthOpts{BearerTokenFile: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+
+	auth, err := newAuthenticator(&AuthOpts{})
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+}
@@ -0,0 +1,62 @@
+package strata
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfoOpts defines the options for the build info metric that is
+// registered by New.
+type BuildInfoOpts struct {
+	// Labels are additional labels that are added to the build info metric.
+	// They take precedence over the labels read from the build information.
+	Labels map[string]string
+}
+
+// buildInfoLabels returns the labels for the build info metric.  The labels
+// are populated from the build information embedded in the binary by the go
+// toolchain with any of the values that are unavailable set to "unknown".
+func buildInfoLabels(info *debug.BuildInfo, ok bool, extra map[string]string) map[string]string {
+	labels := map[string]string{
+		"goversion":     runtime.Version(),
+		"module":        "unknown",
+		"version":       "unknown",
+		"revision":      "unknown",
+		"revision_time": "unknown",
+		"modified":      "unknown",
+	}
+
+	if ok && info != nil {
+		if info.GoVersion != "" {
+			labels["goversion"] = info.GoVersion
+		}
+
+		if info.Main.Path != "" {
+			labels["module"] = info.Main.Path
+		}
+
+		if info.Main.Version != "" {
+			labels["version"] = info.Main.Version
+		}
+
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				labels["revision"] = s.Value
+			case "vcs.time":
+				labels["revision_time"] = s.Value
+			case "vcs.modified":
+				labels["modified"] = s.Value
+			}
+		}
+	}
+
+	return mergeLabels(labels, extra)
+}
+
+func (m *Metrics) registerBuildInfo(opts *BuildInfoOpts) {
+	info, ok := debug.ReadBuildInfo()
+	// The labels depend on the build, so the metric can't be declared.
+	m.store.schema.exempt(infoName(prefixedName(m.prefix, "build", m.separator)))
+	m.Info("build", buildInfoLabels(info, ok, opts.Labels))
+}
@@ -0,0 +1,60 @@
+package strata
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfoLabels(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.22.0",
+		Main: debug.Module{
+			Path:    "ctx.sh/example",
+			Version: "v1.2.3",
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-01-01T00:00:00Z"},
+			{Key: "vcs.modified", Value: "false"},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"goversion":     "go1.22.0",
+		"module":        "ctx.sh/example",
+		"version":       "v1.2.3",
+		"revision":      "abc123",
+		"revision_time": "2024-01-01T00:00:00Z",
+		"modified":      "false",
+		"env":           "prod",
+	}, buildInfoLabels(info, true, map[string]string{"env": "prod"}))
+
+	labels := buildInfoLabels(nil, false, map[string]string{"version": "v0.0.1"})
+	assert.Equal(t, "v0.0.1", labels["version"])
+	assert.Equal(t, "unknown", labels["revision"])
+	assert.NotEmpty(t, labels["goversion"])
+}
+
+func TestMetricsBuildInfo(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m := New(MetricsOpts{
+		Registry:     reg,
+		Prefix:       []string{"strata", "example"},
+		PanicOnError: true,
+		BuildInfo: &BuildInfoOpts{
+			Labels: map[string]string{"env": "prod"},
+		},
+	})
+
+	vec, ok := m.store.infos["strata_example_build_info"]
+	assert.True(t, ok)
+	assert.Equal(t, 1, testutil.CollectAndCount(vec.Vec()))
+
+	count, err := testutil.GatherAndCount(reg, "strata_example_build_info", "go_goroutines")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
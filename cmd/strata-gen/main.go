@@ -0,0 +1,240 @@
+// Command strata-gen generates typed metric accessors from a strata schema.
+//
+// Usage:
+//
+//	strata-gen -schema metrics.yaml -package metrics -type AppMetrics -out metrics_gen.go
+//
+// For every declared metric an accessor is generated that takes one string
+// argument per label, in the declared order, and returns the bound strata
+// handle.  For example, a counter named requests_total with the labels method
+// and code produces:
+//
+//	func (m *AppMetrics) RequestsTotal(method, code string) *strata.Counter
+//
+// The generated type is created from a *strata.Metrics with New<Type>.  The
+// names in the schema are the full metric names, so the Metrics should not
+// have a prefix unless the prefix is also removed from the schema names.  Use
+// -trim-prefix to remove a common prefix from the generated method names.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"ctx.sh/strata"
+)
+
+type options struct {
+	schema     string
+	out        string
+	pkg        string
+	typeName   string
+	trimPrefix string
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "strata-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("strata-gen", flag.ContinueOnError)
+	fs.StringVar(&opts.schema, "schema", "", "path to the YAML or JSON metric schema (required)")
+	fs.StringVar(&opts.out, "out", "", "output file, defaults to stdout")
+	fs.StringVar(&opts.pkg, "package", "metrics", "package name of the generated file")
+	fs.StringVar(&opts.typeName, "type", "AppMetrics", "name of the generated type")
+	fs.StringVar(&opts.trimPrefix, "trim-prefix", "", "prefix removed from the metric names when naming the accessors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if opts.schema == "" {
+		return fmt.Errorf("-schema is required")
+	}
+
+	schema, err := strata.LoadSchema(opts.schema)
+	if err != nil {
+		return err
+	}
+
+	src, err := generate(schema, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.out == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+
+	return os.WriteFile(opts.out, src, 0o644) //nolint:gosec
+}
+
+type accessor struct {
+	Method string
+	Field  string
+	Name   string
+	Help   string
+	Handle string
+	Kind   string
+	Labels []string
+	Params []string
+}
+
+type file struct {
+	Package   string
+	Type      string
+	Schema    string
+	Accessors []accessor
+}
+
+var handles = map[strata.MetricType]string{ //nolint:gochecknoglobals
+	strata.CounterType:   "Counter",
+	strata.GaugeType:     "Gauge",
+	strata.HistogramType: "Histogram",
+	strata.SummaryType:   "Summary",
+}
+
+// generate renders the accessors for the schema and formats the source.
+func generate(schema *strata.Schema, opts options) ([]byte, error) {
+	f := file{
+		Package: opts.pkg,
+		Type:    opts.typeName,
+		Schema:  opts.schema,
+	}
+
+	methods := make(map[string]string, len(schema.Metrics))
+	for _, m := range schema.Metrics {
+		handle, ok := handles[m.Type]
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported type %q", m.Name, m.Type)
+		}
+
+		method := exported(strings.TrimPrefix(m.Name, opts.trimPrefix))
+		if other, ok := methods[method]; ok {
+			return nil, fmt.Errorf("%s and %s both generate the method %s", other, m.Name, method)
+		}
+		methods[method] = m.Name
+
+		params := make([]string, len(m.Labels))
+		for i, label := range m.Labels {
+			params[i] = param(label)
+		}
+
+		f.Accessors = append(f.Accessors, accessor{
+			Method: method,
+			Field:  field(method),
+			Name:   m.Name,
+			Help:   strings.Join(strings.Fields(m.Help), " "),
+			Handle: handle,
+			Kind:   strings.ToLower(handle),
+			Labels: m.Labels,
+			Params: params,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, f); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// initialisms are the name segments that are written in upper case.
+var initialisms = map[string]bool{ //nolint:gochecknoglobals
+	"api": true, "cpu": true, "db": true, "dns": true, "grpc": true, "http": true,
+	"id": true, "io": true, "ip": true, "rpc": true, "sql": true, "tcp": true,
+	"tls": true, "ttl": true, "udp": true, "uri": true, "url": true,
+}
+
+// exported converts a metric or label name to an exported Go identifier.
+func exported(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == ':' }) {
+		if initialisms[part] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	s := b.String()
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		s = "M" + s
+	}
+	return s
+}
+
+// unexported converts an exported identifier to an unexported identifier.  A
+// leading initialism is lowered as a whole, e.g. HTTPRequests becomes
+// httpRequests.
+func unexported(name string) string {
+	runes := []rune(name)
+	i := 0
+	for i < len(runes) && unicode.IsUpper(runes[i]) {
+		i++
+	}
+	if i > 1 && i < len(runes) {
+		i--
+	}
+
+	return strings.ToLower(string(runes[:i])) + string(runes[i:])
+}
+
+// field converts a method name to the name of the struct field.
+func field(method string) string {
+	f := unexported(method)
+	if token.IsKeyword(f) {
+		f += "Metric"
+	}
+	return f
+}
+
+// param converts a label name to a parameter name.
+func param(label string) string {
+	p := unexported(exported(label))
+	if token.IsKeyword(p) || p == "m" {
+		p += "Label"
+	}
+	return p
+}
+
+var tmpl = template.Must(template.New("file").Parse(`// Code generated by strata-gen from {{ .Schema }}. DO NOT EDIT.
+
+package {{ .Package }}
+
+import "ctx.sh/strata"
+
+// {{ .Type }} provides typed accessors for the metrics declared in the schema.
+type {{ .Type }} struct {
+{{- range .Accessors }}
+	{{ .Field }} *strata.Metrics
+{{- end }}
+}
+
+// New{{ .Type }} creates the accessors using the metrics.
+func New{{ .Type }}(m *strata.Metrics) *{{ .Type }} {
+	return &{{ .Type }}{
+{{- range .Accessors }}
+		{{ .Field }}: m.WithLabels({{ range $i, $l := .Labels }}{{ if $i }}, {{ end }}{{ printf "%q" $l }}{{ end }}),
+{{- end }}
+	}
+}
+{{ range .Accessors }}
+// {{ .Method }} returns the {{ .Name }} {{ .Kind }}.{{ if .Help }}  {{ .Help }}{{ end }}
+func (m *{{ $.Type }}) {{ .Method }}({{ range $i, $p := .Params }}{{ if $i }}, {{ end }}{{ $p }}{{ end }}{{ if .Params }} string{{ end }}) *strata.{{ .Handle }} {
+	return m.{{ .Field }}.{{ .Handle }}({{ printf "%q" .Name }}{{ range .Params }}, {{ . }}{{ end }})
+}
+{{ end }}`))
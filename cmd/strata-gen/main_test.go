@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+metrics:
+  - name: api_requests_total
+    type: counter
+    help: Requests served by the API.
+    labels: [method, code]
+  - name: api_http_request_duration_seconds
+    type: histogram
+    unit: seconds
+    labels: [type]
+  - name: api_queue_depth
+    type: gauge
+  - name: api_latency_seconds
+    type: summary
+    labels: [m]
+`
+
+func TestGenerate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testSchema), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"-schema", path, "-trim-prefix", "api_"}, &out))
+	src := out.String()
+
+	assert.Contains(t, src, "// Code generated by strata-gen")
+	assert.Contains(t, src, "func NewAppMetrics(m *strata.Metrics) *AppMetrics {")
+	assert.Contains(t, src, `requestsTotal:              m.WithLabels("method", "code"),`)
+	assert.Contains(t, src, "func (m *AppMetrics) RequestsTotal(method, code string) *strata.Counter {")
+	assert.Contains(t, src, `return m.requestsTotal.Counter("api_requests_total", method, code)`)
+	assert.Contains(t, src, "func (m *AppMetrics) HTTPRequestDurationSeconds(typeLabel string) *strata.Histogram {")
+	assert.Contains(t, src, "func (m *AppMetrics) QueueDepth() *strata.Gauge {")
+	assert.Contains(t, src, "func (m *AppMetrics) LatencySeconds(mLabel string) *strata.Summary {")
+
+	// The generated code type checks against the strata package.
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "metrics_gen.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("metrics", fset, []*ast.File{file}, nil)
+	assert.NoError(t, err)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	assert.Error(t, run([]string{}, &bytes.Buffer{}))
+	assert.Error(t, run([]string{"-schema", filepath.Join(t.TempDir(), "missing.yaml")}, &bytes.Buffer{}))
+
+	path := filepath.Join(t.TempDir(), "metrics.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+metrics:
+  - {name: "requests_total", type: counter}
+  - {name: "requests:total", type: counter}
+`), 0o600))
+	assert.Error(t, run([]string{"-schema", path}, &bytes.Buffer{}))
+}
+
+func TestIdentifiers(t *testing.T) {
+	assert.Equal(t, "HTTPRequestsTotal", exported("http_requests_total"))
+	assert.Equal(t, "M5xxTotal", exported("5xx_total"))
+	assert.Equal(t, "httpRequestsTotal", field("HTTPRequestsTotal"))
+	assert.Equal(t, "api", field("API"))
+	assert.Equal(t, "rangeMetric", field("Range"))
+	assert.Equal(t, "statusCode", param("status_code"))
+	assert.Equal(t, "typeLabel", param("type"))
+	assert.Equal(t, "errorMetric", param("error_metric"))
+}
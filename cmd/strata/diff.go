@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// family summarizes the schema of a metric family.
+type family struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Series  int       `json:"series"`
+	Labels  []string  `json:"labels,omitempty"`
+	Buckets []float64 `json:"buckets,omitempty"`
+}
+
+type typeChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type labelChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+type bucketChange struct {
+	Added   []float64 `json:"added,omitempty"`
+	Removed []float64 `json:"removed,omitempty"`
+}
+
+type seriesChange struct {
+	Old   int `json:"old"`
+	New   int `json:"new"`
+	Delta int `json:"delta"`
+}
+
+type familyChange struct {
+	Name    string        `json:"name"`
+	Type    *typeChange   `json:"type,omitempty"`
+	Labels  *labelChange  `json:"labels,omitempty"`
+	Buckets *bucketChange `json:"buckets,omitempty"`
+	Series  *seriesChange `json:"series,omitempty"`
+}
+
+// report is the difference between two expositions.
+type report struct {
+	Old     string         `json:"old"`
+	New     string         `json:"new"`
+	Added   []family       `json:"added"`
+	Removed []family       `json:"removed"`
+	Changed []familyChange `json:"changed"`
+}
+
+func (r *report) empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var format string
+	fs := flag.NewFlagSet("strata diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&format, "format", "text", "output format, text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: strata diff [flags] <old> <new>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if fs.NArg() != 2 || (format != "text" && format != "json") {
+		fs.Usage()
+		return exitError
+	}
+
+	expositions := make([]*exposition, 2)
+	for i, source := range fs.Args() {
+		e, err := load(context.Background(), source, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "strata diff:", err)
+			return exitError
+		}
+		expositions[i] = e
+	}
+
+	r := diff(expositions[0], expositions[1])
+	if format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			fmt.Fprintln(stderr, "strata diff:", err)
+			return exitError
+		}
+	} else {
+		printReport(stdout, r)
+	}
+
+	if r.empty() {
+		return exitOK
+	}
+	return exitViolation
+}
+
+// diff compares the families of the expositions.
+func diff(before, after *exposition) *report {
+	r := &report{
+		Old:     before.source,
+		New:     after.source,
+		Added:   []family{},
+		Removed: []family{},
+		Changed: []familyChange{},
+	}
+
+	old := summarize(before)
+	cur := summarize(after)
+
+	for _, name := range sortedFamilies(old, cur) {
+		o, inOld := old[name]
+		n, inNew := cur[name]
+		switch {
+		case !inOld:
+			r.Added = append(r.Added, n)
+		case !inNew:
+			r.Removed = append(r.Removed, o)
+		default:
+			if c, ok := compare(o, n); ok {
+				r.Changed = append(r.Changed, c)
+			}
+		}
+	}
+
+	return r
+}
+
+// compare returns the changes between two versions of a family and false if
+// there are none.
+func compare(o, n family) (familyChange, bool) {
+	c := familyChange{Name: o.Name}
+	changed := false
+
+	if o.Type != n.Type {
+		c.Type = &typeChange{Old: o.Type, New: n.Type}
+		changed = true
+	}
+
+	if added, removed := setDiff(o.Labels, n.Labels); len(added) > 0 || len(removed) > 0 {
+		c.Labels = &labelChange{Added: added, Removed: removed}
+		changed = true
+	}
+
+	if added, removed := setDiff(o.Buckets, n.Buckets); len(added) > 0 || len(removed) > 0 {
+		c.Buckets = &bucketChange{Added: added, Removed: removed}
+		changed = true
+	}
+
+	if o.Series != n.Series {
+		c.Series = &seriesChange{Old: o.Series, New: n.Series, Delta: n.Series - o.Series}
+		changed = true
+	}
+
+	return c, changed
+}
+
+func summarize(e *exposition) map[string]family {
+	families := make(map[string]family, len(e.families))
+	for _, mf := range e.families {
+		f := family{
+			Name:   mf.GetName(),
+			Type:   strings.ToLower(mf.GetType().String()),
+			Series: len(mf.GetMetric()),
+			Labels: sortedLabels(labelValues(mf)),
+		}
+		if mf.GetType() == dto.MetricType_HISTOGRAM {
+			f.Buckets = bucketBounds(mf)
+		}
+		families[f.Name] = f
+	}
+	return families
+}
+
+// bucketBounds returns the finite bucket boundaries used by the series of a
+// histogram.  The +Inf bucket is always present and is left out.
+func bucketBounds(mf *dto.MetricFamily) []float64 {
+	seen := make(map[float64]bool)
+	bounds := make([]float64, 0)
+	for _, m := range mf.GetMetric() {
+		for _, b := range m.GetHistogram().GetBucket() {
+			ub := b.GetUpperBound()
+			if math.IsInf(ub, 1) || seen[ub] {
+				continue
+			}
+			seen[ub] = true
+			bounds = append(bounds, ub)
+		}
+	}
+	sort.Float64s(bounds)
+	return bounds
+}
+
+func sortedFamilies(before, after map[string]family) []string {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setDiff returns the values of b that are not in a, and the values of a that
+// are not in b, preserving the order of the sorted inputs.
+func setDiff[T comparable](a, b []T) ([]T, []T) {
+	in := func(s []T, v T) bool {
+		for _, x := range s {
+			if x == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	var added, removed []T
+	for _, v := range b {
+		if !in(a, v) {
+			added = append(added, v)
+		}
+	}
+	for _, v := range a {
+		if !in(b, v) {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
+
+func printReport(w io.Writer, r *report) {
+	if r.empty() {
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", r.Old, r.New)
+	for _, f := range r.Removed {
+		fmt.Fprintf(w, "- %s (%s, %d series)\n", f.Name, f.Type, f.Series)
+	}
+	for _, f := range r.Added {
+		fmt.Fprintf(w, "+ %s (%s, %d series)\n", f.Name, f.Type, f.Series)
+	}
+	for _, c := range r.Changed {
+		fmt.Fprintf(w, "~ %s\n", c.Name)
+		if c.Type != nil {
+			fmt.Fprintf(w, "    type: %s -> %s\n", c.Type.Old, c.Type.New)
+		}
+		if c.Labels != nil {
+			fmt.Fprintf(w, "    labels: %s\n", formatChanges(c.Labels.Added, c.Labels.Removed, func(s string) string { return s }))
+		}
+		if c.Buckets != nil {
+			fmt.Fprintf(w, "    buckets: %s\n", formatChanges(c.Buckets.Added, c.Buckets.Removed, func(f float64) string {
+				return strconv.FormatFloat(f, 'g', -1, 64)
+			}))
+		}
+		if c.Series != nil {
+			fmt.Fprintf(w, "    series: %d -> %d (%+d)\n", c.Series.Old, c.Series.New, c.Series.Delta)
+		}
+	}
+}
+
+func formatChanges[T any](added, removed []T, format func(T) string) string {
+	parts := make([]string, 0, len(added)+len(removed))
+	for _, v := range added {
+		parts = append(parts, "+"+format(v))
+	}
+	for _, v := range removed {
+		parts = append(parts, "-"+format(v))
+	}
+	return strings.Join(parts, " ")
+}
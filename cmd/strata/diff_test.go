@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oldExposition = `# HELP jobs_total Jobs.
+# TYPE jobs_total counter
+jobs_total{queue="a"} 1
+# HELP queue_depth Depth.
+# TYPE queue_depth gauge
+queue_depth{queue="a"} 1
+# HELP workers Workers.
+# TYPE workers gauge
+workers 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="0.25"} 1
+latency_seconds_bucket{method="GET",le="+Inf"} 1
+latency_seconds_sum{method="GET"} 0.05
+latency_seconds_count{method="GET"} 1
+`
+
+const newExposition = `# HELP jobs_total Jobs.
+# TYPE jobs_total counter
+jobs_total{queue="a"} 1
+# HELP queue_depth Depth.
+# TYPE queue_depth counter
+queue_depth{queue="a"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{code="200",le="0.1"} 1
+latency_seconds_bucket{code="200",le="0.5"} 1
+latency_seconds_bucket{code="200",le="+Inf"} 1
+latency_seconds_sum{code="200"} 0.05
+latency_seconds_count{code="200"} 1
+latency_seconds_bucket{code="500",le="0.1"} 1
+latency_seconds_bucket{code="500",le="0.5"} 1
+latency_seconds_bucket{code="500",le="+Inf"} 1
+latency_seconds_sum{code="500"} 0.05
+latency_seconds_count{code="500"} 1
+# HELP errors_total Errors.
+# TYPE errors_total counter
+errors_total 0
+`
+
+func TestDiffText(t *testing.T) {
+	before := writeFile(t, oldExposition)
+	after := writeFile(t, newExposition)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"diff", before, after}, nil, &stdout, &stderr)
+	assert.Equal(t, exitViolation, code, stderr.String())
+	assert.Equal(t, `--- `+before+`
++++ `+after+`
+- workers (gauge, 1 series)
++ errors_total (counter, 1 series)
+~ latency_seconds
+    labels: +code -method
+    buckets: +0.5 -0.25
+    series: 1 -> 2 (+1)
+~ queue_depth
+    type: gauge -> counter
+`, stdout.String())
+}
+
+func TestDiffJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"diff", "-format", "json", writeFile(t, oldExposition), writeFile(t, newExposition)}, nil, &stdout, &stderr)
+	assert.Equal(t, exitViolation, code, stderr.String())
+
+	var r report
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &r))
+	assert.Equal(t, []family{{Name: "errors_total", Type: "counter", Series: 1}}, r.Added)
+	assert.Equal(t, []family{{Name: "workers", Type: "gauge", Series: 1}}, r.Removed)
+	require.Len(t, r.Changed, 2)
+	assert.Equal(t, familyChange{
+		Name:    "latency_seconds",
+		Labels:  &labelChange{Added: []string{"code"}, Removed: []string{"method"}},
+		Buckets: &bucketChange{Added: []float64{0.5}, Removed: []float64{0.25}},
+		Series:  &seriesChange{Old: 1, New: 2, Delta: 1},
+	}, r.Changed[0])
+	assert.Equal(t, familyChange{
+		Name: "queue_depth",
+		Type: &typeChange{Old: "gauge", New: "counter"},
+	}, r.Changed[1])
+}
+
+func TestDiffJSONFamilies(t *testing.T) {
+	before := `# HELP jobs_total Jobs.
+# TYPE jobs_total counter
+jobs_total{queue="a",state="done"} 1
+`
+	after := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="0.5"} 1
+latency_seconds_bucket{method="GET",le="+Inf"} 1
+latency_seconds_sum{method="GET"} 0.05
+latency_seconds_count{method="GET"} 1
+`
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"diff", "-format", "json", writeFile(t, before), writeFile(t, after)}, nil, &stdout, &stderr)
+	assert.Equal(t, exitViolation, code, stderr.String())
+
+	// The labels and buckets of the added and removed families are included.
+	var raw struct {
+		Added   []map[string]any `json:"added"`
+		Removed []map[string]any `json:"removed"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &raw))
+	assert.Equal(t, []any{"method"}, raw.Added[0]["labels"])
+	assert.Equal(t, []any{0.1, 0.5}, raw.Added[0]["buckets"])
+	assert.Equal(t, []any{"queue", "state"}, raw.Removed[0]["labels"])
+
+	var r report
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &r))
+	assert.Equal(t, []family{{
+		Name:    "latency_seconds",
+		Type:    "histogram",
+		Series:  1,
+		Labels:  []string{"method"},
+		Buckets: []float64{0.1, 0.5},
+	}}, r.Added)
+	assert.Equal(t, []family{{
+		Name:   "jobs_total",
+		Type:   "counter",
+		Series: 1,
+		Labels: []string{"queue", "state"},
+	}}, r.Removed)
+}
+
+func TestDiffIdentical(t *testing.T) {
+	path := writeFile(t, oldExposition)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{"diff", path, path}, nil, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, exitOK, run([]string{"diff", "-format", "json", path, path}, nil, &stdout, &stderr))
+	assert.JSONEq(t, `{"old":"`+path+`","new":"`+path+`","added":[],"removed":[],"changed":[]}`, stdout.String())
+}
+
+func TestDiffErrors(t *testing.T) {
+	path := writeFile(t, oldExposition)
+
+	tests := map[string][]string{
+		"one input":      {"diff", path},
+		"invalid format": {"diff", "-format", "yaml", path, path},
+		"missing file":   {"diff", path, path + ".missing"},
+	}
+
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, exitError, run(args, nil, &stdout, &stderr))
+			assert.NotEmpty(t, stderr.String())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// fetchTimeout limits the time spent fetching an exposition from a URL.
+const fetchTimeout = 30 * time.Second
+
+// exposition holds the metric families read from a file or URL, sorted by name.
+type exposition struct {
+	source   string
+	families []*dto.MetricFamily
+}
+
+// load reads and parses the text exposition from a URL, a file or stdin if
+// the source is -.
+func load(ctx context.Context, source string, stdin io.Reader) (*exposition, error) {
+	var r io.Reader
+	switch {
+	case source == "-":
+		r = stdin
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		body, err := fetch(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		r = body
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return parse(source, r)
+}
+
+func fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// Only the text format is parsed, so OpenMetrics and protobuf are not
+	// requested.
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+
+	return &cancelBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelBody cancels the request context when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func parse(source string, r io.Reader) (*exposition, error) {
+	var parser expfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	e := &exposition{
+		source:   source,
+		families: make([]*dto.MetricFamily, 0, len(mfs)),
+	}
+	for _, mf := range mfs {
+		e.families = append(e.families, mf)
+	}
+	sort.Slice(e.families, func(i, j int) bool {
+		return e.families[i].GetName() < e.families[j].GetName()
+	})
+
+	return e, nil
+}
+
+// labelValues returns the number of distinct values of each label of the
+// family.
+func labelValues(mf *dto.MetricFamily) map[string]int {
+	values := make(map[string]map[string]struct{})
+	for _, m := range mf.GetMetric() {
+		for _, lp := range m.GetLabel() {
+			if values[lp.GetName()] == nil {
+				values[lp.GetName()] = make(map[string]struct{})
+			}
+			values[lp.GetName()][lp.GetValue()] = struct{}{}
+		}
+	}
+
+	counts := make(map[string]int, len(values))
+	for name, v := range values {
+		counts[name] = len(v)
+	}
+	return counts
+}
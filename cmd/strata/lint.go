@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ctx.sh/strata"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The lint rules.  Rules can be disabled with the -disable flag.
+const (
+	ruleName         = "name"
+	ruleLabelName    = "label-name"
+	ruleCounterTotal = "counter-total"
+	ruleTotalSuffix  = "total-suffix"
+	ruleBaseUnit     = "base-unit"
+	ruleHelp         = "help"
+	ruleDefaultHelp  = "default-help"
+	ruleCardinality  = "cardinality"
+	ruleBuckets      = "buckets"
+)
+
+var (
+	snakeCaseRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`) //nolint:gochecknoglobals
+
+	// nonBaseUnits maps the name segments of units that should be converted
+	// to their base unit.
+	nonBaseUnits = map[string]string{ //nolint:gochecknoglobals
+		"nanoseconds":  "seconds",
+		"microseconds": "seconds",
+		"milliseconds": "seconds",
+		"ns":           "seconds",
+		"us":           "seconds",
+		"ms":           "seconds",
+		"minutes":      "seconds",
+		"hours":        "seconds",
+		"days":         "seconds",
+		"kilobytes":    "bytes",
+		"megabytes":    "bytes",
+		"gigabytes":    "bytes",
+		"kb":           "bytes",
+		"mb":           "bytes",
+		"gb":           "bytes",
+		"percent":      "ratio",
+	}
+)
+
+type lintOptions struct {
+	maxSeries  int
+	maxBuckets int
+	disabled   map[string]bool
+	verbose    bool
+}
+
+type violation struct {
+	family  string
+	rule    string
+	message string
+}
+
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := lintOptions{}
+	var disable string
+	fs := flag.NewFlagSet("strata lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.IntVar(&opts.maxSeries, "max-series", 1000, "maximum number of series per family")
+	fs.IntVar(&opts.maxBuckets, "max-buckets", 40, "maximum number of buckets per histogram")
+	fs.StringVar(&disable, "disable", "", "comma separated rules that are not checked")
+	fs.BoolVar(&opts.verbose, "v", false, "print the label cardinality of every family")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: strata lint [flags] <file|url>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	opts.disabled = make(map[string]bool)
+	for _, rule := range strings.Split(disable, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			opts.disabled[rule] = true
+		}
+	}
+
+	code := exitOK
+	for _, source := range fs.Args() {
+		e, err := load(context.Background(), source, stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "strata lint:", err)
+			return exitError
+		}
+
+		if opts.verbose {
+			printCardinality(stdout, e)
+		}
+
+		for _, v := range lint(e, opts) {
+			fmt.Fprintf(stdout, "%s: %s: [%s] %s\n", e.source, v.family, v.rule, v.message)
+			code = exitViolation
+		}
+	}
+
+	return code
+}
+
+// lint checks the families of the exposition and returns the violations
+// ordered by family.
+func lint(e *exposition, opts lintOptions) []violation {
+	var violations []violation
+	for _, mf := range e.families {
+		report := func(rule, format string, args ...any) {
+			if !opts.disabled[rule] {
+				violations = append(violations, violation{mf.GetName(), rule, fmt.Sprintf(format, args...)})
+			}
+		}
+
+		lintName(mf, report)
+		lintHelp(mf, report)
+		lintLabels(mf, opts, report)
+		if mf.GetType() == dto.MetricType_HISTOGRAM {
+			lintBuckets(mf, opts, report)
+		}
+	}
+
+	return violations
+}
+
+type reportFunc func(rule, format string, args ...any)
+
+func lintName(mf *dto.MetricFamily, report reportFunc) {
+	name := mf.GetName()
+	if !snakeCaseRegexp.MatchString(name) {
+		report(ruleName, "name should be lower snake case, colons are reserved for recording rules")
+	}
+	if strings.HasPrefix(name, "__") {
+		report(ruleName, "names starting with __ are reserved")
+	}
+
+	isTotal := strings.HasSuffix(name, "_total")
+	switch {
+	case mf.GetType() == dto.MetricType_COUNTER && !isTotal:
+		report(ruleCounterTotal, "counter names should end with _total")
+	case mf.GetType() != dto.MetricType_COUNTER && isTotal:
+		report(ruleTotalSuffix, "the _total suffix is reserved for counters")
+	}
+
+	for _, segment := range strings.Split(strings.ToLower(name), "_") {
+		if base, ok := nonBaseUnits[segment]; ok {
+			report(ruleBaseUnit, "use the base unit %s instead of %s", base, segment)
+		}
+	}
+}
+
+func lintHelp(mf *dto.MetricFamily, report reportFunc) {
+	help := strings.TrimSpace(mf.GetHelp())
+	switch {
+	case help == "":
+		report(ruleHelp, "missing HELP")
+	case help == strata.DefaultHelpString:
+		report(ruleDefaultHelp, "HELP is the strata default %q", strata.DefaultHelpString)
+	}
+}
+
+func lintLabels(mf *dto.MetricFamily, opts lintOptions, report reportFunc) {
+	values := labelValues(mf)
+	for _, name := range sortedLabels(values) {
+		if !snakeCaseRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+			report(ruleLabelName, "label %s should be lower snake case and must not start with __", name)
+		}
+	}
+
+	if series := len(mf.GetMetric()); opts.maxSeries > 0 && series > opts.maxSeries {
+		report(ruleCardinality, "%d series exceed the limit of %d (%s)", series, opts.maxSeries, formatCardinality(values))
+	}
+}
+
+func lintBuckets(mf *dto.MetricFamily, opts lintOptions, report reportFunc) {
+	// Every series has the same buckets, so only the first problem of each
+	// kind is reported.
+	seen := make(map[string]bool)
+	once := func(key, format string, args ...any) {
+		if !seen[key] {
+			seen[key] = true
+			report(ruleBuckets, format, args...)
+		}
+	}
+
+	for _, m := range mf.GetMetric() {
+		h := m.GetHistogram()
+		buckets := h.GetBucket()
+
+		finite := 0
+		for i, b := range buckets {
+			if !math.IsInf(b.GetUpperBound(), 1) {
+				finite++
+			}
+			if i == 0 {
+				continue
+			}
+			prev := buckets[i-1]
+			if b.GetUpperBound() <= prev.GetUpperBound() {
+				once("order", "bucket boundaries are not strictly increasing (%v after %v)", b.GetUpperBound(), prev.GetUpperBound())
+			}
+			if b.GetCumulativeCount() < prev.GetCumulativeCount() {
+				once("cumulative", "bucket counts are not cumulative (le=%v has %d, le=%v has %d)",
+					b.GetUpperBound(), b.GetCumulativeCount(), prev.GetUpperBound(), prev.GetCumulativeCount())
+			}
+		}
+
+		if finite == 0 {
+			once("empty", "histogram has no buckets besides +Inf")
+		}
+		if opts.maxBuckets > 0 && finite > opts.maxBuckets {
+			once("max", "%d buckets exceed the limit of %d", finite, opts.maxBuckets)
+		}
+
+		if n := len(buckets); n > 0 && math.IsInf(buckets[n-1].GetUpperBound(), 1) &&
+			buckets[n-1].GetCumulativeCount() != h.GetSampleCount() {
+			once("count", "the +Inf bucket (%d) doesn't match the count (%d)", buckets[n-1].GetCumulativeCount(), h.GetSampleCount())
+		}
+	}
+}
+
+// printCardinality prints the number of series and the distinct values of
+// each label of the families.
+func printCardinality(w io.Writer, e *exposition) {
+	for _, mf := range e.families {
+		values := labelValues(mf)
+		if len(values) == 0 {
+			fmt.Fprintf(w, "%s: %s: %d series\n", e.source, mf.GetName(), len(mf.GetMetric()))
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s: %d series (%s)\n", e.source, mf.GetName(), len(mf.GetMetric()), formatCardinality(values))
+	}
+}
+
+// formatCardinality formats the distinct values of the labels, highest first.
+func formatCardinality(values map[string]int) string {
+	names := sortedLabels(values)
+	sort.SliceStable(names, func(i, j int) bool {
+		return values[names[i]] > values[names[j]]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, values[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedLabels(values map[string]int) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
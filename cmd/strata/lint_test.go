@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ctx.sh/strata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cleanExposition = `# HELP api_requests_total Requests served by the API.
+# TYPE api_requests_total counter
+api_requests_total{code="200",method="GET"} 10
+api_requests_total{code="500",method="GET"} 1
+# HELP api_request_duration_seconds Duration of the API requests.
+# TYPE api_request_duration_seconds histogram
+api_request_duration_seconds_bucket{le="0.1"} 1
+api_request_duration_seconds_bucket{le="1"} 3
+api_request_duration_seconds_bucket{le="+Inf"} 4
+api_request_duration_seconds_sum 5.5
+api_request_duration_seconds_count 4
+`
+
+const badExposition = `# TYPE requests counter
+requests 1
+# HELP queue_depth_total created automagically by strata
+# TYPE queue_depth_total gauge
+queue_depth_total{Queue="a"} 1
+# HELP latency_ms Latency.
+# TYPE latency_ms histogram
+latency_ms_bucket{le="10"} 3
+latency_ms_bucket{le="5"} 2
+latency_ms_bucket{le="+Inf"} 4
+latency_ms_sum 20
+latency_ms_count 5
+# HELP empty_seconds No buckets.
+# TYPE empty_seconds histogram
+empty_seconds_bucket{le="+Inf"} 1
+empty_seconds_sum 1
+empty_seconds_count 1
+`
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLintClean(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", writeFile(t, cleanExposition)}, nil, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Empty(t, stdout.String())
+}
+
+func TestLintViolations(t *testing.T) {
+	path := writeFile(t, badExposition)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", path}, nil, &stdout, &stderr)
+	assert.Equal(t, exitViolation, code)
+
+	expected := []string{
+		"empty_seconds: [buckets] histogram has no buckets besides +Inf",
+		"latency_ms: [base-unit] use the base unit seconds instead of ms",
+		"latency_ms: [buckets] bucket boundaries are not strictly increasing (5 after 10)",
+		"latency_ms: [buckets] bucket counts are not cumulative (le=5 has 2, le=10 has 3)",
+		"latency_ms: [buckets] the +Inf bucket (4) doesn't match the count (5)",
+		"queue_depth_total: [total-suffix] the _total suffix is reserved for counters",
+		fmt.Sprintf("queue_depth_total: [default-help] HELP is the strata default %q", strata.DefaultHelpString),
+		"queue_depth_total: [label-name] label Queue should be lower snake case and must not start with __",
+		"requests: [counter-total] counter names should end with _total",
+		"requests: [help] missing HELP",
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, len(expected), stdout.String())
+	for i, line := range lines {
+		assert.Equal(t, path+": "+expected[i], line)
+	}
+}
+
+func TestLintDisable(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{
+		"lint", "-disable", "buckets,base-unit,total-suffix,default-help,label-name,counter-total,help",
+		writeFile(t, badExposition),
+	}, nil, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stdout.String())
+}
+
+func TestLintCardinality(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# HELP jobs_total Jobs.\n# TYPE jobs_total counter\n")
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&b, "jobs_total{queue=\"q%d\",type=\"a\"} 1\n", i)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", "-max-series", "3", "-"}, strings.NewReader(b.String()), &stdout, &stderr)
+	assert.Equal(t, exitViolation, code)
+	assert.Equal(t, "-: jobs_total: [cardinality] 5 series exceed the limit of 3 (queue=5, type=1)\n", stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"lint", "-v", "-"}, strings.NewReader(b.String()), &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "-: jobs_total: 5 series (queue=5, type=1)\n", stdout.String())
+}
+
+func TestLintURL(t *testing.T) {
+	m := strata.New(strata.MetricsOpts{PanicOnError: true})
+	m.CounterInc("jobs_total")
+	srv := httptest.NewServer(strata.HandlerFor(m))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", "-disable", "buckets", srv.URL}, nil, &stdout, &stderr)
+	assert.Equal(t, exitViolation, code, stderr.String())
+	assert.Contains(t, stdout.String(), srv.URL+": jobs_total: [default-help]")
+}
+
+func TestLintErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	tests := map[string][]string{
+		"no inputs":       {"lint"},
+		"missing file":    {"lint", filepath.Join(t.TempDir(), "missing.prom")},
+		"invalid input":   {"lint", writeFile(t, "not a metric {\n")},
+		"bad status":      {"lint", srv.URL},
+		"unknown flag":    {"lint", "-unknown"},
+		"unknown command": {"vet"},
+		"no command":      {},
+	}
+
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, exitError, run(args, nil, &stdout, &stderr))
+			assert.NotEmpty(t, stderr.String())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Command strata inspects Prometheus text expositions, such as the output of
+// a /metrics endpoint.
+//
+// Usage:
+//
+//	strata lint [flags] <file|url>...
+//	strata diff [flags] <old> <new>
+//
+// The lint subcommand reports the families that violate the naming
+// conventions, are missing help text, exceed the cardinality limit or expose
+// invalid histogram buckets.  It exits with status 1 if there are violations
+// so it can be used to gate pipelines, and with status 2 if an input can't be
+// read.
+//
+// The diff subcommand reports the families that were added or removed and the
+// changes to the type, label names, histogram buckets and number of series of
+// the remaining families, as text or JSON.  It exits with status 1 if the
+// expositions differ.
+//
+// The inputs are files, http(s) URLs or - to read from stdin.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK        = 0
+	exitViolation = 1
+	exitError     = 2
+)
+
+const usage = `Usage: strata <command> [flags] <file|url>...
+
+Commands:
+  lint    report violations of the metric conventions
+  diff    report the changes between two expositions
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	switch args[0] {
+	case "lint":
+		return runLint(args[1:], stdin, stdout, stderr)
+	case "diff":
+		return runDiff(args[1:], stdin, stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "strata: unknown command %q\n\n%s", args[0], usage)
+		return exitError
+	}
+}
@@ -0,0 +1,59 @@
+package strata
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// CollectorsOpts defines the options for the go runtime and process collectors
+// that are registered by New.
+type CollectorsOpts struct {
+	// DisableGoCollector disables the registration of the go runtime
+	// collector.  This is commonly used when multiple Metrics share a single
+	// registry.
+	DisableGoCollector bool
+	// DisableProcessCollector disables the registration of the process
+	// collector.
+	DisableProcessCollector bool
+	// GoRuntimeMetricsRules enables additional metrics from runtime/metrics in
+	// the go collector, for example collectors.MetricsGC or
+	// collectors.MetricsScheduler for the GC and scheduler latency histograms.
+	GoRuntimeMetricsRules []collectors.GoRuntimeMetricsRule
+	// ProcessNamespace is the namespace used for the process collector metrics.
+	ProcessNamespace string
+}
+
+// registerCollectors registers the go runtime and process collectors.  Errors
+// are logged and reported through emitError rather than being discarded.
+func (m *Metrics) registerCollectors(opts *CollectorsOpts) {
+	if !opts.DisableGoCollector {
+		collector := collectors.NewGoCollector()
+		if len(opts.GoRuntimeMetricsRules) > 0 {
+			collector = collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(opts.GoRuntimeMetricsRules...))
+		}
+
+		m.registerCollector("go_collector", collector)
+	}
+
+	if !opts.DisableProcessCollector {
+		m.registerCollector("process_collector", collectors.NewProcessCollector(collectors.ProcessCollectorOpts{
+			Namespace: opts.ProcessNamespace,
+		}))
+	}
+}
+
+func (m *Metrics) registerCollector(name string, collector prometheus.Collector) {
+	defer m.recover(name, "register_collector")
+	if err := Register(m.registry, collector); err != nil {
+		m.logger.Error(err, "unable to register collector", "name", name)
+		m.emitError(err, name, "register_collector")
+	}
+}
+
+func defaultedCollectors(opts *CollectorsOpts) *CollectorsOpts {
+	if opts == nil {
+		opts = &CollectorsOpts{}
+	}
+
+	return opts
+}
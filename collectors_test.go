@@ -0,0 +1,59 @@
+package strata
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorsDisabled(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	_ = New(MetricsOpts{
+		Registry: reg,
+		Collectors: &CollectorsOpts{
+			DisableGoCollector:      true,
+			DisableProcessCollector: true,
+		},
+	})
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Empty(t, mfs)
+}
+
+func TestCollectorsOpts(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_ = New(MetricsOpts{
+		Registry: reg,
+		Collectors: &CollectorsOpts{
+			GoRuntimeMetricsRules: []collectors.GoRuntimeMetricsRule{collectors.MetricsScheduler},
+			ProcessNamespace:      "strata",
+		},
+	})
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	names := make(map[string]bool)
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	assert.True(t, names["go_sched_latencies_seconds"])
+	assert.True(t, names["strata_process_start_time_seconds"])
+	assert.False(t, names["process_start_time_seconds"])
+}
+
+func TestCollectorsRegistrationError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_ = New(MetricsOpts{Registry: reg})
+
+	assert.PanicsWithValue(t, ErrAlreadyRegistered, func() {
+		_ = New(MetricsOpts{Registry: reg, PanicOnError: true})
+	})
+
+	assert.NotPanics(t, func() {
+		_ = New(MetricsOpts{Registry: reg})
+	})
+}
@@ -0,0 +1,281 @@
+package strata
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by
+// OptsFromEnv when no prefix is provided.
+const DefaultEnvPrefix = "STRATA"
+
+// Config holds the metrics and server options that can be configured from
+// environment variables and flags, so every service exposes the same knobs.
+type Config struct {
+	Metrics MetricsOpts
+	Server  ServerOpts
+}
+
+// OptsFromEnv creates a Config from the environment variables with the
+// prefix, or DefaultEnvPrefix if the prefix is empty.  Unset variables leave
+// the defaults in place.  The following variables are read:
+//
+//	<PREFIX>_BIND_ADDR                  ServerOpts.BindAddr
+//	<PREFIX>_PORT                       ServerOpts.Port
+//	<PREFIX>_PATH                       ServerOpts.Path
+//	<PREFIX>_TERMINATION_GRACE_PERIOD   ServerOpts.TerminationGracePeriod
+//	<PREFIX>_TLS_CERT_FILE              TLSOpts.CertFile
+//	<PREFIX>_TLS_KEY_FILE               TLSOpts.KeyFile
+//	<PREFIX>_TLS_CLIENT_CA_FILE         TLSOpts.ClientCAFile
+//	<PREFIX>_PREFIX                     MetricsOpts.Prefix, comma separated
+//	<PREFIX>_CONSTANT_LABELS            MetricsOpts.ConstantLabels, k=v,k2=v2
+//	<PREFIX>_PANIC_ON_ERROR             MetricsOpts.PanicOnError
+func OptsFromEnv(prefix string) (*Config, error) {
+	c := &Config{}
+	if err := c.FromEnv(prefix); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FromEnv applies the environment variables read by OptsFromEnv to the
+// Config.  Unset variables leave the current values in place, which allows
+// an application to set its own defaults first.  Example:
+//
+//	config := &strata.Config{}
+//	config.Metrics.PanicOnError = true
+//	if err := config.FromEnv(""); err != nil {
+//		log.Fatal(err)
+//	}
+func (c *Config) FromEnv(prefix string) error {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	if c.Server.TLS == nil {
+		c.Server.TLS = &TLSOpts{}
+	}
+
+	vars := []struct {
+		name  string
+		value flag.Value
+	}{
+		{"BIND_ADDR", (*stringValue)(&c.Server.BindAddr)},
+		{"PORT", (*intValue)(&c.Server.Port)},
+		{"PATH", (*stringValue)(&c.Server.Path)},
+		{"TERMINATION_GRACE_PERIOD", (*durationValue)(&c.Server.TerminationGracePeriod)},
+		{"TLS_CERT_FILE", (*stringValue)(&c.Server.TLS.CertFile)},
+		{"TLS_KEY_FILE", (*stringValue)(&c.Server.TLS.KeyFile)},
+		{"TLS_CLIENT_CA_FILE", (*stringValue)(&c.Server.TLS.ClientCAFile)},
+		{"PREFIX", (*prefixValue)(&c.Metrics.Prefix)},
+		{"CONSTANT_LABELS", (*labelsValue)(&c.Metrics.ConstantLabels)},
+		{"PANIC_ON_ERROR", (*boolValue)(&c.Metrics.PanicOnError)},
+	}
+
+	for _, v := range vars {
+		name := prefix + "_" + v.name
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := v.value.Set(value); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidConfig, name, err)
+		}
+	}
+
+	return nil
+}
+
+// RegisterFlags registers the metrics and server flags with the flag set and
+// returns the Config that is populated when the flags are parsed.
+func RegisterFlags(fs *flag.FlagSet) *Config {
+	return (&Config{}).RegisterFlags(fs)
+}
+
+// RegisterFlags registers the metrics and server flags with the flag set using
+// the current values as the defaults, which allows flags to override the
+// values read with OptsFromEnv or FromEnv.  Example:
+//
+//	config, err := strata.OptsFromEnv("")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	config.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//
+//	metrics := strata.New(config.Metrics)
+//	err = metrics.Start(ctx, config.Server)
+func (c *Config) RegisterFlags(fs *flag.FlagSet) *Config {
+	if c.Server.TLS == nil {
+		c.Server.TLS = &TLSOpts{}
+	}
+
+	fs.Var((*stringValue)(&c.Server.BindAddr), "metrics-bind-addr", "address the metrics server listens on")
+	fs.Var((*intValue)(&c.Server.Port), "metrics-port", "port the metrics server listens on")
+	fs.Var((*stringValue)(&c.Server.Path), "metrics-path", "path of the metrics endpoint")
+	fs.Var((*durationValue)(&c.Server.TerminationGracePeriod), "metrics-termination-grace-period",
+		"time to wait for a final scrape when shutting down")
+	fs.Var((*stringValue)(&c.Server.TLS.CertFile), "metrics-tls-cert-file", "path to the TLS certificate")
+	fs.Var((*stringValue)(&c.Server.TLS.KeyFile), "metrics-tls-key-file", "path to the TLS key")
+	fs.Var((*stringValue)(&c.Server.TLS.ClientCAFile), "metrics-tls-client-ca-file",
+		"path to the certificate authorities used to verify clients")
+	fs.Var((*prefixValue)(&c.Metrics.Prefix), "metrics-prefix", "comma separated metric name prefixes")
+	fs.Var((*labelsValue)(&c.Metrics.ConstantLabels), "metrics-constant-labels",
+		"comma separated constant labels in the form k=v,k2=v2")
+	fs.Var((*boolValue)(&c.Metrics.PanicOnError), "metrics-panic-on-error", "panic on metric errors")
+
+	return c
+}
+
+// ParseConstantLabels parses constant labels in the form k=v,k2=v2 into
+// label/value pairs.  The label names must be valid prometheus label names
+// and may only be used once.
+func ParseConstantLabels(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return []string{}, nil
+	}
+
+	seen := make(map[string]bool)
+	pairs := make([]string, 0)
+	for _, kv := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(kv, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("%q is not in the form name=value", kv)
+		}
+
+		if err := validateLabelName(name); err != nil {
+			return nil, err
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("label %q is defined more than once", name)
+		}
+		seen[name] = true
+
+		pairs = append(pairs, name, strings.TrimSpace(value))
+	}
+
+	return pairs, nil
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	*v = intValue(i)
+	return nil
+}
+
+func (v *intValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string {
+	if v == nil {
+		return "false"
+	}
+	return strconv.FormatBool(bool(*v))
+}
+
+// IsBoolFlag allows the flag to be used without a value.
+func (v *boolValue) IsBoolFlag() bool {
+	return true
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string {
+	if v == nil {
+		return "0s"
+	}
+	return time.Duration(*v).String()
+}
+
+type prefixValue []string
+
+func (v *prefixValue) Set(s string) error {
+	prefix := make([]string, 0)
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefix = append(prefix, p)
+		}
+	}
+	*v = prefix
+	return nil
+}
+
+func (v *prefixValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ",")
+}
+
+type labelsValue []string
+
+func (v *labelsValue) Set(s string) error {
+	pairs, err := ParseConstantLabels(s)
+	if err != nil {
+		return err
+	}
+	*v = pairs
+	return nil
+}
+
+func (v *labelsValue) String() string {
+	if v == nil {
+		return ""
+	}
+
+	kvs := make([]string, 0, len(*v)/2)
+	for i := 0; i+1 < len(*v); i += 2 {
+		kvs = append(kvs, (*v)[i]+"="+(*v)[i+1])
+	}
+	return strings.Join(kvs, ",")
+}
@@ -0,0 +1,158 @@
+package strata
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptsFromEnv(t *testing.T) {
+	t.Setenv("STRATA_PORT", "9191")
+	t.Setenv("STRATA_PATH", "/stats")
+	t.Setenv("STRATA_BIND_ADDR", "127.0.0.1")
+	t.Setenv("STRATA_TERMINATION_GRACE_PERIOD", "5s")
+	t.Setenv("STRATA_TLS_CERT_FILE", "tls.crt")
+	t.Setenv("STRATA_TLS_KEY_FILE", "tls.key")
+	t.Setenv("STRATA_PREFIX", "app, api")
+	t.Setenv("STRATA_CONSTANT_LABELS", "role=server,region=us-east-1")
+	t.Setenv("STRATA_PANIC_ON_ERROR", "true")
+
+	c, err := OptsFromEnv("")
+	require.NoError(t, err)
+
+	assert.Equal(t, 9191, c.Server.Port)
+	assert.Equal(t, "/stats", c.Server.Path)
+	assert.Equal(t, "127.0.0.1", c.Server.BindAddr)
+	assert.Equal(t, 5*time.Second, c.Server.TerminationGracePeriod)
+	assert.Equal(t, "tls.crt", c.Server.TLS.CertFile)
+	assert.Equal(t, "tls.key", c.Server.TLS.KeyFile)
+	assert.Equal(t, []string{"app", "api"}, c.Metrics.Prefix)
+	assert.Equal(t, []string{"role", "server", "region", "us-east-1"}, c.Metrics.ConstantLabels)
+	assert.True(t, c.Metrics.PanicOnError)
+}
+
+func TestOptsFromEnvPrefix(t *testing.T) {
+	t.Setenv("STRATA_PORT", "9191")
+	t.Setenv("APP_METRICS_PORT", "9292")
+
+	c, err := OptsFromEnv("APP_METRICS")
+	require.NoError(t, err)
+	assert.Equal(t, 9292, c.Server.Port)
+	assert.Empty(t, c.Metrics.ConstantLabels)
+}
+
+func TestConfigFromEnvKeepsDefaults(t *testing.T) {
+	t.Setenv("STRATA_PORT", "9191")
+
+	c := &Config{}
+	c.Metrics.PanicOnError = true
+	c.Server.Path = "/custom"
+	require.NoError(t, c.FromEnv(""))
+	assert.Equal(t, 9191, c.Server.Port)
+	assert.Equal(t, "/custom", c.Server.Path)
+	assert.True(t, c.Metrics.PanicOnError)
+
+	t.Setenv("STRATA_PANIC_ON_ERROR", "false")
+	require.NoError(t, c.FromEnv(""))
+	assert.False(t, c.Metrics.PanicOnError)
+}
+
+func TestOptsFromEnvInvalid(t *testing.T) {
+	tests := map[string]string{
+		"STRATA_PORT":                     "http",
+		"STRATA_TERMINATION_GRACE_PERIOD": "5",
+		"STRATA_PANIC_ON_ERROR":           "sometimes",
+		"STRATA_CONSTANT_LABELS":          "role",
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := OptsFromEnv("")
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, name)
+		})
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c := RegisterFlags(fs)
+
+	err := fs.Parse([]string{
+		"-metrics-port", "9191",
+		"-metrics-path", "/stats",
+		"-metrics-tls-client-ca-file", "ca.crt",
+		"-metrics-constant-labels", "role=server",
+		"-metrics-panic-on-error",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9191, c.Server.Port)
+	assert.Equal(t, "/stats", c.Server.Path)
+	assert.Equal(t, "ca.crt", c.Server.TLS.ClientCAFile)
+	assert.Equal(t, []string{"role", "server"}, c.Metrics.ConstantLabels)
+	assert.True(t, c.Metrics.PanicOnError)
+}
+
+func TestRegisterFlagsOverridesEnv(t *testing.T) {
+	t.Setenv("STRATA_PORT", "9191")
+	t.Setenv("STRATA_CONSTANT_LABELS", "role=server")
+
+	c, err := OptsFromEnv("")
+	require.NoError(t, err)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	assert.Equal(t, "9191", fs.Lookup("metrics-port").DefValue)
+	assert.Equal(t, "role=server", fs.Lookup("metrics-constant-labels").DefValue)
+
+	require.NoError(t, fs.Parse([]string{"-metrics-port", "9292"}))
+	assert.Equal(t, 9292, c.Server.Port)
+	assert.Equal(t, []string{"role", "server"}, c.Metrics.ConstantLabels)
+}
+
+func TestRegisterFlagsInvalidLabels(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	RegisterFlags(fs)
+
+	err := fs.Parse([]string{"-metrics-constant-labels", "1role=server"})
+	assert.ErrorContains(t, err, "invalid label name")
+}
+
+func TestParseConstantLabels(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		err      string
+	}{
+		{"", []string{}, ""},
+		{"role=server", []string{"role", "server"}, ""},
+		{" role = server , zone=a ", []string{"role", "server", "zone", "a"}, ""},
+		{"role=", []string{"role", ""}, ""},
+		{"url=http://host/?a=b", []string{"url", "http://host/?a=b"}, ""},
+		{"role", nil, "not in the form name=value"},
+		{"role=server,", nil, "not in the form name=value"},
+		{"=server", nil, "invalid label name"},
+		{"my-role=server", nil, "invalid label name"},
+		{"__name__=server", nil, "invalid label name"},
+		{"role=a,role=b", nil, "more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			pairs, err := ParseConstantLabels(tt.value)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, pairs)
+		})
+	}
+}
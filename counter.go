@@ -30,15 +30,20 @@ import (
 // thing partitioned by various dimensions (e.g. number of HTTP requests,
 // partitioned by response code and method).
 type CounterVec struct {
-	vec  *prometheus.CounterVec
-	name string
+	vec    *prometheus.CounterVec
+	name   string
+	labels []string
 }
 
 // NewCounterVec creates, registers, and returns a new CounterVec.
 func NewCounterVec(registerer prometheus.Registerer, name string, labels ...string) (*CounterVec, error) {
+	return newCounterVec(registerer, name, DefaultHelpString, labels...)
+}
+
+func newCounterVec(registerer prometheus.Registerer, name string, help string, labels ...string) (*CounterVec, error) {
 	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: name,
-		Help: DefaultHelpString,
+		Help: help,
 	}, labels)
 
 	if err := Register(registerer, counter); err != nil {
@@ -46,8 +51,9 @@ func NewCounterVec(registerer prometheus.Registerer, name string, labels ...stri
 	}
 
 	return &CounterVec{
-		name: name,
-		vec:  counter,
+		name:   name,
+		vec:    counter,
+		labels: labels,
 	}, nil
 }
 
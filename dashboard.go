@@ -0,0 +1,359 @@
+package strata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultDashboardTitle is the title of the generated dashboard.
+	DefaultDashboardTitle = "strata"
+	// DefaultDashboardRefresh is the refresh interval of the generated
+	// dashboard.
+	DefaultDashboardRefresh = "30s"
+
+	panelWidth  = 12
+	panelHeight = 8
+)
+
+// DefaultDashboardQuantiles are the quantiles shown for histograms.
+var DefaultDashboardQuantiles = []float64{0.5, 0.9, 0.99} //nolint:gochecknoglobals
+
+// DashboardOpts defines the options for the generated Grafana dashboard.
+type DashboardOpts struct {
+	// Title is the title of the dashboard.  By default DefaultDashboardTitle
+	// is used.
+	Title string
+	// UID is the unique identifier of the dashboard.  By default Grafana
+	// assigns one when the dashboard is imported.
+	UID string
+	// Tags are the tags of the dashboard.
+	Tags []string
+	// Refresh is the refresh interval of the dashboard.  By default
+	// DefaultDashboardRefresh is used.
+	Refresh string
+	// Quantiles are the quantiles calculated with histogram_quantile for the
+	// histograms.  By default DefaultDashboardQuantiles are used.
+	Quantiles []float64
+}
+
+// Dashboard generates a starter Grafana dashboard for the metrics that have
+// been created.  Counters are shown as rates, histograms as a heatmap and the
+// quantiles calculated with histogram_quantile, gauges as their values and
+// summaries as their quantiles.  A template variable is added for the Prometheus
+// datasource and for each constant label.  Since metrics are created when they
+// are first used, the dashboard should be generated once all of the metrics
+// have been observed or declared in a schema.  Example:
+//
+//	data, err := metrics.Dashboard(strata.DashboardOpts{Title: "api"})
+//	if err != nil {
+//		return err
+//	}
+//	err = os.WriteFile("dashboard.json", data, 0o644)
+func (m *Metrics) Dashboard(opts DashboardOpts) ([]byte, error) {
+	opts = defaultedDashboard(opts)
+
+	labels := sortedKeys(m.constantLabels)
+	b := &dashboardBuilder{
+		opts:     opts,
+		selector: dashboardSelector(labels),
+	}
+
+	dashboard := grafanaDashboard{
+		UID:           opts.UID,
+		Title:         opts.Title,
+		Tags:          opts.Tags,
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       opts.Refresh,
+		Time:          grafanaTime{From: "now-6h", To: "now"},
+		Templating:    grafanaTemplating{List: dashboardVariables(labels)},
+		Panels:        b.build(m.store.descriptors()),
+	}
+
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode dashboard: %w", err)
+	}
+
+	return data, nil
+}
+
+type dashboardBuilder struct {
+	opts     DashboardOpts
+	selector string
+	out      []grafanaPanel
+}
+
+func (b *dashboardBuilder) build(descs []descriptor) []grafanaPanel {
+	b.out = make([]grafanaPanel, 0, len(descs))
+
+	for _, d := range descs {
+		by := strings.Join(d.labels, ", ")
+		legend := dashboardLegend(d.labels)
+
+		switch d.mtype {
+		case CounterType:
+			b.add("timeseries", d.name+" rate", dashboardRateUnit(d.name), grafanaTarget{
+				Expr:         aggregate("sum", by, fmt.Sprintf("rate(%s%s[$__rate_interval])", d.name, b.selector)),
+				LegendFormat: legend,
+			})
+		case GaugeType:
+			b.add("timeseries", d.name, dashboardUnit(d.name), grafanaTarget{
+				Expr:         d.name + b.selector,
+				LegendFormat: legend,
+			})
+		case HistogramType:
+			b.add("heatmap", d.name+" heatmap", dashboardUnit(d.name), grafanaTarget{
+				Expr:         fmt.Sprintf("sum by (le) (rate(%s_bucket%s[$__rate_interval]))", d.name, b.selector),
+				LegendFormat: "{{le}}",
+				Format:       "heatmap",
+			})
+
+			targets := make([]grafanaTarget, len(b.opts.Quantiles))
+			for i, q := range b.opts.Quantiles {
+				targets[i] = grafanaTarget{
+					Expr: fmt.Sprintf("histogram_quantile(%s, sum by (%s) (rate(%s_bucket%s[$__rate_interval])))",
+						formatQuantile(q), strings.Join(append([]string{"le"}, d.labels...), ", "), d.name, b.selector),
+					LegendFormat: quantileLegend(q, d.labels),
+				}
+			}
+			b.add("timeseries", d.name+" quantiles", dashboardUnit(d.name), targets...)
+		case SummaryType:
+			if len(d.quantiles) == 0 {
+				continue
+			}
+			targets := make([]grafanaTarget, len(d.quantiles))
+			for i, q := range d.quantiles {
+				targets[i] = grafanaTarget{
+					Expr:         fmt.Sprintf("%s%s", d.name, withMatcher(b.selector, fmt.Sprintf("quantile=%q", formatQuantile(q)))),
+					LegendFormat: quantileLegend(q, d.labels),
+				}
+			}
+			b.add("timeseries", d.name+" quantiles", dashboardUnit(d.name), targets...)
+		}
+	}
+
+	return b.out
+}
+
+// add appends a panel, laying the panels out in two columns.
+func (b *dashboardBuilder) add(kind, title, unit string, targets ...grafanaTarget) {
+	id := len(b.out) + 1
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+		targets[i].Datasource = grafanaDatasourceRef
+	}
+
+	b.out = append(b.out, grafanaPanel{
+		ID:         id,
+		Type:       kind,
+		Title:      title,
+		Datasource: grafanaDatasourceRef,
+		GridPos: grafanaGridPos{
+			H: panelHeight,
+			W: panelWidth,
+			X: (id - 1) % 2 * panelWidth,
+			Y: (id - 1) / 2 * panelHeight,
+		},
+		FieldConfig: grafanaFieldConfig{Defaults: grafanaFieldDefaults{Unit: unit}},
+		Targets:     targets,
+	})
+}
+
+// dashboardSelector returns the label matchers that select the values of the
+// constant label template variables.
+func dashboardSelector(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	matchers := make([]string, len(labels))
+	for i, l := range labels {
+		matchers[i] = fmt.Sprintf(`%s=~"$%s"`, l, l)
+	}
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// withMatcher adds a label matcher to the selector.
+func withMatcher(selector, matcher string) string {
+	if selector == "" {
+		return "{" + matcher + "}"
+	}
+	return strings.TrimSuffix(selector, "}") + ", " + matcher + "}"
+}
+
+func dashboardVariables(labels []string) []grafanaVariable {
+	vars := []grafanaVariable{{
+		Name:  "datasource",
+		Label: "Data source",
+		Type:  "datasource",
+		Query: "prometheus",
+	}}
+
+	for _, l := range labels {
+		vars = append(vars, grafanaVariable{
+			Name:       l,
+			Label:      l,
+			Type:       "query",
+			Datasource: &grafanaDatasourceRef,
+			Query:      fmt.Sprintf("label_values(%s)", l),
+			Refresh:    2,
+			IncludeAll: true,
+			Multi:      true,
+			AllValue:   ".*",
+		})
+	}
+
+	return vars
+}
+
+func aggregate(op, by, expr string) string {
+	if by == "" {
+		return fmt.Sprintf("%s(%s)", op, expr)
+	}
+	return fmt.Sprintf("%s by (%s) (%s)", op, by, expr)
+}
+
+func dashboardLegend(labels []string) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = "{{" + l + "}}"
+	}
+	return strings.Join(parts, " ")
+}
+
+func quantileLegend(q float64, labels []string) string {
+	legend := "p" + strconv.FormatFloat(q*100, 'g', 6, 64)
+	if len(labels) > 0 {
+		legend += " " + dashboardLegend(labels)
+	}
+	return legend
+}
+
+func formatQuantile(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// dashboardUnit returns the Grafana unit for the base unit in the name of the
+// metric.
+func dashboardUnit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(name, "_bytes"):
+		return "bytes"
+	case strings.HasSuffix(name, "_ratio"):
+		return "percentunit"
+	default:
+		return "short"
+	}
+}
+
+// dashboardRateUnit returns the Grafana unit for the rate of a counter.
+func dashboardRateUnit(name string) string {
+	switch dashboardUnit(name) {
+	case "s":
+		return "s"
+	case "bytes":
+		return "Bps"
+	default:
+		return "cps"
+	}
+}
+
+func defaultedDashboard(opts DashboardOpts) DashboardOpts {
+	if opts.Title == "" {
+		opts.Title = DefaultDashboardTitle
+	}
+
+	if opts.Refresh == "" {
+		opts.Refresh = DefaultDashboardRefresh
+	}
+
+	if opts.Tags == nil {
+		opts.Tags = []string{}
+	}
+
+	if len(opts.Quantiles) == 0 {
+		opts.Quantiles = DefaultDashboardQuantiles
+	}
+
+	return opts
+}
+
+var grafanaDatasourceRef = grafanaDatasource{Type: "prometheus", UID: "${datasource}"} //nolint:gochecknoglobals
+
+type grafanaDashboard struct {
+	UID           string            `json:"uid,omitempty"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Timezone      string            `json:"timezone"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          grafanaTime       `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       string             `json:"type"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Query      string             `json:"query"`
+	Refresh    int                `json:"refresh,omitempty"`
+	IncludeAll bool               `json:"includeAll,omitempty"`
+	Multi      bool               `json:"multi,omitempty"`
+	AllValue   string             `json:"allValue,omitempty"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+	Targets     []grafanaTarget    `json:"targets"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults grafanaFieldDefaults `json:"defaults"`
+}
+
+type grafanaFieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type grafanaTarget struct {
+	RefID        string            `json:"refId"`
+	Datasource   grafanaDatasource `json:"datasource"`
+	Expr         string            `json:"expr"`
+	LegendFormat string            `json:"legendFormat,omitempty"`
+	Format       string            `json:"format,omitempty"`
+}
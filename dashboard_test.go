@@ -0,0 +1,108 @@
+package strata
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	m := New(MetricsOpts{
+		Registry:       prometheus.NewRegistry(),
+		ConstantLabels: []string{"service", "api", "env", "prod"},
+		PanicOnError:   true,
+		SummaryOpts:    &SummaryOpts{Objectives: map[float64]float64{0.5: 0.05, 0.999: 0.0001}},
+	})
+
+	m.WithLabels("method", "code").CounterInc("requests_total", "GET", "200")
+	m.GaugeSet("queue_depth", 3)
+	m.WithLabels("method").HistogramObserve("request_duration_seconds", 0.2, "GET")
+	m.SummaryObserve("response_size_bytes", 512)
+
+	data, err := m.Dashboard(DashboardOpts{Title: "api", UID: "api", Tags: []string{"api"}})
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+
+	assert.Equal(t, "api", dashboard.Title)
+	assert.Equal(t, "api", dashboard.UID)
+	assert.Equal(t, []string{"api"}, dashboard.Tags)
+	assert.Equal(t, DefaultDashboardRefresh, dashboard.Refresh)
+
+	vars := dashboard.Templating.List
+	require.Len(t, vars, 3)
+	assert.Equal(t, "datasource", vars[0].Type)
+	assert.Equal(t, "env", vars[1].Name)
+	assert.Equal(t, "label_values(env)", vars[1].Query)
+	assert.Equal(t, "service", vars[2].Name)
+
+	sel := `{env=~"$env", service=~"$service"}`
+	expected := []struct {
+		kind  string
+		title string
+		unit  string
+		exprs []string
+	}{
+		{"timeseries", "queue_depth", "short", []string{"queue_depth" + sel}},
+		{"heatmap", "request_duration_seconds heatmap", "s", []string{
+			"sum by (le) (rate(request_duration_seconds_bucket" + sel + "[$__rate_interval]))",
+		}},
+		{"timeseries", "request_duration_seconds quantiles", "s", []string{
+			"histogram_quantile(0.5, sum by (le, method) (rate(request_duration_seconds_bucket" + sel + "[$__rate_interval])))",
+			"histogram_quantile(0.9, sum by (le, method) (rate(request_duration_seconds_bucket" + sel + "[$__rate_interval])))",
+			"histogram_quantile(0.99, sum by (le, method) (rate(request_duration_seconds_bucket" + sel + "[$__rate_interval])))",
+		}},
+		{"timeseries", "requests_total rate", "cps", []string{
+			"sum by (method, code) (rate(requests_total" + sel + "[$__rate_interval]))",
+		}},
+		{"timeseries", "response_size_bytes quantiles", "bytes", []string{
+			`response_size_bytes{env=~"$env", service=~"$service", quantile="0.5"}`,
+			`response_size_bytes{env=~"$env", service=~"$service", quantile="0.999"}`,
+		}},
+	}
+
+	require.Len(t, dashboard.Panels, len(expected))
+	for i, e := range expected {
+		p := dashboard.Panels[i]
+		assert.Equal(t, i+1, p.ID)
+		assert.Equal(t, e.kind, p.Type)
+		assert.Equal(t, e.title, p.Title)
+		assert.Equal(t, e.unit, p.FieldConfig.Defaults.Unit, e.title)
+		assert.Equal(t, grafanaGridPos{H: panelHeight, W: panelWidth, X: i % 2 * panelWidth, Y: i / 2 * panelHeight}, p.GridPos)
+
+		exprs := make([]string, len(p.Targets))
+		for j, target := range p.Targets {
+			exprs[j] = target.Expr
+			assert.Equal(t, string(rune('A'+j)), target.RefID)
+		}
+		assert.Equal(t, e.exprs, exprs, e.title)
+	}
+
+	assert.Equal(t, "p99.9", dashboard.Panels[4].Targets[1].LegendFormat)
+	assert.Equal(t, "p50 {{method}}", dashboard.Panels[2].Targets[0].LegendFormat)
+}
+
+func TestDashboardWithoutConstantLabels(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true})
+	m.CounterInc("jobs_total")
+	m.WithLabels("queue").SummaryObserve("wait_seconds", 1, "a")
+	m.CounterFuncVec("tasks_total", []string{"queue", "state"}, func() map[string]float64 { return nil })
+
+	data, err := m.Dashboard(DashboardOpts{})
+	require.NoError(t, err)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+
+	assert.Equal(t, DefaultDashboardTitle, dashboard.Title)
+	assert.Len(t, dashboard.Templating.List, 1)
+	require.Len(t, dashboard.Panels, 3)
+	assert.Equal(t, "sum(rate(jobs_total[$__rate_interval]))", dashboard.Panels[0].Targets[0].Expr)
+	// The labels of the callback metrics are used for the aggregation.
+	assert.Equal(t, "sum by (queue, state) (rate(tasks_total[$__rate_interval]))", dashboard.Panels[1].Targets[0].Expr)
+	assert.Equal(t, `wait_seconds{quantile="0.5"}`, dashboard.Panels[2].Targets[0].Expr)
+}
@@ -0,0 +1,75 @@
+package strata
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DebugOpts defines the options for the debug endpoints.  Nothing is mounted
+// unless handlers are provided so profiles are never exposed by accident.  The
+// pprof and expvar handlers are provided by the debug package, which is not
+// imported by strata because importing net/http/pprof and expvar registers
+// their handlers on http.DefaultServeMux.  The endpoints are guarded by the
+// same authentication settings as the metrics endpoint.
+type DebugOpts struct {
+	// Handlers maps the paths of the debug endpoints to their handlers, e.g.
+	// the handlers returned by debug.Handlers.  Paths ending with a slash
+	// match all the paths below them.
+	Handlers map[string]http.Handler
+	// BindAddr is the address the separate debug listener binds to.  By
+	// default the BindAddr of the server is used, unless the server listens
+	// on a unix socket, which can't be shared.
+	BindAddr string
+	// Port enables a separate listener for the debug endpoints on BindAddr and
+	// Port.  By default the endpoints are mounted on the metrics server.
+	Port int
+	// EphemeralPort enables a separate listener that binds port 0 so the
+	// operating system chooses a free port.  See Metrics.DebugAddr.
+	EphemeralPort bool
+}
+
+// separate returns true if the debug endpoints are served on their own
+// listener.
+func (d *DebugOpts) separate() bool {
+	return d.Port != 0 || d.EphemeralPort
+}
+
+func (d *DebugOpts) enabled() bool {
+	return len(d.Handlers) > 0
+}
+
+// mountDebug adds the debug endpoints to the mux.
+func mountDebug(mux *http.ServeMux, opts *DebugOpts, auth *authenticator) {
+	for path, handler := range opts.Handlers {
+		mux.Handle(path, auth.wrap(handler))
+	}
+}
+
+// defaultedDebug returns a copy of the options with the defaults applied.  A
+// TCP host of the server is inherited, but a Unix domain socket can't be
+// shared, so the separate listener requires its own BindAddr in that case.
+func defaultedDebug(opts *DebugOpts, bindAddr string) *DebugOpts {
+	d := DebugOpts{}
+	if opts != nil {
+		d = *opts
+	}
+
+	if d.BindAddr == "" && !strings.HasPrefix(bindAddr, unixPrefix) {
+		d.BindAddr = bindAddr
+	}
+
+	if d.EphemeralPort {
+		d.Port = 0
+	}
+
+	return &d
+}
+
+// validate checks that the separate listener has an address to bind to.
+func (d *DebugOpts) validate() error {
+	if d.enabled() && d.separate() && d.BindAddr == "" {
+		return fmt.Errorf("%w: the debug listener requires a BindAddr when the server listens on a unix socket", ErrInvalidEndpoint)
+	}
+	return nil
+}
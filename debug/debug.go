@@ -0,0 +1,52 @@
+// Package debug provides the pprof and expvar handlers for the debug
+// endpoints of the strata server.  Importing the package also registers the
+// handlers on http.DefaultServeMux, as net/http/pprof and expvar do, which is
+// why they are not part of the strata package.  Example:
+//
+//	err := metrics.Start(ctx, strata.ServerOpts{
+//		Debug: &strata.DebugOpts{
+//			Handlers: debug.Handlers(debug.Opts{EnablePprof: true}),
+//		},
+//	})
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+const (
+	// PprofPath is the path the pprof handlers are mounted under.
+	PprofPath = "/debug/pprof/"
+	// ExpvarPath is the path the expvar handler is mounted on.
+	ExpvarPath = "/debug/vars"
+)
+
+// Opts defines the debug handlers that are returned by Handlers.
+type Opts struct {
+	// EnablePprof adds the net/http/pprof handlers under /debug/pprof/.
+	EnablePprof bool
+	// EnableExpvar adds the expvar handler on /debug/vars.
+	EnableExpvar bool
+}
+
+// Handlers returns the enabled debug handlers keyed by the path they are
+// mounted on.  The result is used as the Handlers of strata.DebugOpts.
+func Handlers(opts Opts) map[string]http.Handler {
+	handlers := make(map[string]http.Handler)
+
+	if opts.EnablePprof {
+		handlers[PprofPath] = http.HandlerFunc(pprof.Index)
+		handlers[PprofPath+"cmdline"] = http.HandlerFunc(pprof.Cmdline)
+		handlers[PprofPath+"profile"] = http.HandlerFunc(pprof.Profile)
+		handlers[PprofPath+"symbol"] = http.HandlerFunc(pprof.Symbol)
+		handlers[PprofPath+"trace"] = http.HandlerFunc(pprof.Trace)
+	}
+
+	if opts.EnableExpvar {
+		handlers[ExpvarPath] = expvar.Handler()
+	}
+
+	return handlers
+}
@@ -0,0 +1,27 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlers(t *testing.T) {
+	mux := http.NewServeMux()
+	for path, handler := range Handlers(Opts{EnablePprof: true, EnableExpvar: true}) {
+		mux.Handle(path, handler)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap", "/debug/vars"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+	}
+}
+
+func TestHandlersDisabled(t *testing.T) {
+	assert.Empty(t, Handlers(Opts{}))
+	assert.Len(t, Handlers(Opts{EnableExpvar: true}), 1)
+}
@@ -0,0 +1,126 @@
+package strata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// debugHandlers returns handlers on the paths of the debug package without
+// importing it.
+func debugHandlers() map[string]http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return map[string]http.Handler{"/debug/pprof/": ok, "/debug/vars": ok}
+}
+
+func TestMountDebug(t *testing.T) {
+	mux := http.NewServeMux()
+	mountDebug(mux, &DebugOpts{Handlers: debugHandlers()}, nil)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/vars"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+	}
+}
+
+func TestMountDebugDisabled(t *testing.T) {
+	mux := http.NewServeMux()
+	mountDebug(mux, &DebugOpts{}, nil)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+}
+
+func TestDefaultServeMuxUntouched(t *testing.T) {
+	// Importing strata must not register the pprof handlers on the default
+	// mux.  The expvar handler is registered by the prometheus client, which
+	// imports expvar.
+	_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Empty(t, pattern)
+}
+
+func TestMountDebugAuth(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(file, []byte("s3cr3t"), 0o600))
+	auth, err := newAuthenticator(&AuthOpts{BearerTokenFile: file})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mountDebug(mux, &DebugOpts{Handlers: debugHandlers()}, auth)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServerDebugSeparateListener(t *testing.T) {
+	m := New(MetricsOpts{})
+	assert.Nil(t, m.DebugAddr())
+
+	cancel, _ := startTestServer(t, m, ServerOpts{
+		BindAddr:      "127.0.0.1",
+		EphemeralPort: true,
+		Debug: &DebugOpts{
+			Handlers:      debugHandlers(),
+			EphemeralPort: true,
+		},
+	})
+	defer cancel()
+
+	require.NotNil(t, m.DebugAddr())
+	assert.NotEqual(t, m.Addr().String(), m.DebugAddr().String())
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/vars", m.DebugAddr()))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/debug/vars", m.Addr()))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestDefaultedDebug(t *testing.T) {
+	opts := &DebugOpts{Port: 6060}
+	d := defaultedDebug(opts, "127.0.0.1")
+	assert.Equal(t, "127.0.0.1", d.BindAddr)
+	// The options of the caller are not modified.
+	assert.Empty(t, opts.BindAddr)
+
+	// A unix socket of the server is not inherited.
+	assert.Empty(t, defaultedDebug(opts, "unix:///tmp/metrics.sock").BindAddr)
+	assert.Equal(t, "127.0.0.1", defaultedDebug(&DebugOpts{BindAddr: "127.0.0.1"}, "unix:///tmp/metrics.sock").BindAddr)
+	assert.NotNil(t, defaultedDebug(nil, "127.0.0.1"))
+}
+
+func TestServerDebugUnixSocket(t *testing.T) {
+	m := New(MetricsOpts{})
+	err := m.Start(context.Background(), ServerOpts{
+		BindAddr: "unix://" + filepath.Join(t.TempDir(), "metrics.sock"),
+		Debug: &DebugOpts{
+			Handlers: debugHandlers(),
+			Port:     6060,
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidEndpoint)
+}
@@ -42,6 +42,43 @@ const (
 	ErrNoMetrics = StrataError("no metrics found in context")
 	// ErrNilContext is returned if the context is nil.
 	ErrNilContext = StrataError("context is nil")
+	// ErrInvalidLabelValues is returned if the number of label values does
+	// not match the number of labels.
+	ErrInvalidLabelValues = StrataError("inconsistent label cardinality")
+	// ErrUnknownState is returned if a StateSet is set to a state that it was
+	// not created with.
+	ErrUnknownState = StrataError("unknown state")
+	// ErrInvalidMatcher is returned if a label matcher in a scrape request
+	// can't be parsed.
+	ErrInvalidMatcher = StrataError("invalid label matcher")
+	// ErrInvalidEndpoint is returned if an additional server endpoint is
+	// invalid or conflicts with a path that is already served.
+	ErrInvalidEndpoint = StrataError("invalid endpoint")
+	// ErrInvalidSchema is returned if a schema can't be parsed or declares
+	// invalid metrics.
+	ErrInvalidSchema = StrataError("invalid schema")
+	// ErrUndeclaredMetric is returned in strict mode if a metric is not
+	// declared in the schema.
+	ErrUndeclaredMetric = StrataError("metric is not declared in the schema")
+	// ErrSchemaMismatch is returned if the type or labels of a metric don't
+	// match its declaration in the schema.
+	ErrSchemaMismatch = StrataError("metric does not match the schema")
+	// ErrCardinalityLimit is returned if an observation would exceed the
+	// declared cardinality limit of a metric.
+	ErrCardinalityLimit = StrataError("cardinality limit exceeded")
+	// ErrInvalidConfig is returned if an environment variable holds an
+	// invalid value.
+	ErrInvalidConfig = StrataError("invalid configuration")
+	// ErrInvalidConstantLabels is returned by NewWithError if the constant
+	// labels are invalid, defined more than once or collide with variable
+	// labels.
+	ErrInvalidConstantLabels = StrataError("invalid constant labels")
+	// ErrInvalidSLI is returned if a service level indicator is invalid or
+	// declared more than once.
+	ErrInvalidSLI = StrataError("invalid SLI")
+	// ErrInvalidSLO is returned if the options of a service level objective
+	// are invalid.
+	ErrInvalidSLO = StrataError("invalid SLO")
 )
 
 // Error implements the error interface for StrataError.
@@ -56,6 +93,8 @@ type ApexInternalErrorMetrics struct {
 	errInvalidMetricName  *prometheus.CounterVec
 	errRegistrationFailed *prometheus.CounterVec
 	errAlreadyRegistered  *prometheus.CounterVec
+	errSchemaViolation    *prometheus.CounterVec
+	errCardinalityLimit   *prometheus.CounterVec
 }
 
 // NewApexInternalErrorMetrics defines and registers the internal collectors and
@@ -79,16 +118,28 @@ func NewApexInternalErrorMetrics(prefixes []string, sep rune) *ApexInternalError
 		Name: prefix + "_already_registered",
 	}, []string{"name", "type"})
 
+	errSchemaViolation := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prefix + "_schema_violation",
+	}, []string{"name", "type"})
+
+	errCardinalityLimit := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prefix + "_cardinality_limit_exceeded",
+	}, []string{"name", "type"})
+
 	register(errPanicRecovery)
 	register(errInvalidMetricName)
 	register(errRegistrationFailed)
 	register(errAlreadyRegistered)
+	register(errSchemaViolation)
+	register(errCardinalityLimit)
 
 	return &ApexInternalErrorMetrics{
 		errPanicRecovery:      errPanicRecovery,
 		errInvalidMetricName:  errInvalidMetricName,
 		errRegistrationFailed: errRegistrationFailed,
 		errAlreadyRegistered:  errAlreadyRegistered,
+		errSchemaViolation:    errSchemaViolation,
+		errCardinalityLimit:   errCardinalityLimit,
 	}
 }
 
@@ -128,6 +179,24 @@ func (a *ApexInternalErrorMetrics) AlreadyRegistered(name string, t string) {
 	}).Inc()
 }
 
+// SchemaViolation provides a helper function for incrementing the
+// errSchemaViolation collector.
+func (a *ApexInternalErrorMetrics) SchemaViolation(name string, t string) {
+	a.errSchemaViolation.With(prometheus.Labels{
+		"name": name,
+		"type": t,
+	}).Inc()
+}
+
+// CardinalityLimitExceeded provides a helper function for incrementing the
+// errCardinalityLimit collector.
+func (a *ApexInternalErrorMetrics) CardinalityLimitExceeded(name string, t string) {
+	a.errCardinalityLimit.With(prometheus.Labels{
+		"name": name,
+		"type": t,
+	}).Inc()
+}
+
 func register(metric prometheus.Collector) {
 	if err := prometheus.Register(metric); err != nil {
 		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
@@ -69,8 +69,18 @@ func runOnce(ctx context.Context) error {
 }
 
 func main() {
-	certFile := flag.String("cert", "", "path to the ssl cert")
-	keyFile := flag.String("key", "", "path to the ssl key")
+	// The defaults of the example are set first so the environment and the
+	// flags can override them.
+	config := &strata.Config{}
+	config.Metrics.PanicOnError = true
+	config.Metrics.ConstantLabels = []string{"role", "server"}
+	config.Server.TerminationGracePeriod = 10 * time.Second
+	if err := config.FromEnv(""); err != nil {
+		panic(err)
+	}
+	config.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&config.Server.TLS.CertFile, "cert", config.Server.TLS.CertFile, "path to the ssl cert")
+	flag.StringVar(&config.Server.TLS.KeyFile, "key", config.Server.TLS.KeyFile, "path to the ssl key")
 	flag.Parse()
 
 	encoderCfg := zap.NewProductionEncoderConfig()
@@ -96,32 +106,23 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	metrics := strata.New(strata.MetricsOpts{
-		Logger:         logger,
-		Separator:      ':',
-		PanicOnError:   true,
-		ConstantLabels: []string{"role", "server"},
-		SummaryOpts: &strata.SummaryOpts{
-			MaxAge:     10 * time.Minute,
-			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-			AgeBuckets: 5,
-		},
-		HistogramBuckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
-	}).WithPrefix("strata", "example")
+	config.Metrics.Logger = logger
+	config.Metrics.Separator = ':'
+	config.Metrics.SummaryOpts = &strata.SummaryOpts{
+		MaxAge:     10 * time.Minute,
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		AgeBuckets: 5,
+	}
+	config.Metrics.HistogramBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+	metrics := strata.New(config.Metrics).WithPrefix("strata", "example")
 
 	var obs sync.WaitGroup
 	obs.Add(1)
 	go func() {
 		defer obs.Done()
 		logger.Info("starting metrics")
-		err := metrics.Start(ctx, strata.ServerOpts{
-			Port:                   9090,
-			TerminationGracePeriod: 10 * time.Second,
-			TLS: &strata.TLSOpts{
-				CertFile: *certFile,
-				KeyFile:  *keyFile,
-			},
-		})
+		err := metrics.Start(ctx, config.Server)
 		if err != nil {
 			panic("could not start metrics")
 		}
@@ -0,0 +1,181 @@
+package strata
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// NameParam is the query parameter used to select metric families by
+	// name.  It may be repeated.
+	NameParam = "name[]"
+	// MatchParam is the query parameter used to select series with a label
+	// matcher such as job="api" or code=~"5..".  It may be repeated and all of
+	// the matchers must match.
+	MatchParam = "match[]"
+
+	matchEqual     = "="
+	matchNotEqual  = "!="
+	matchRegexp    = "=~"
+	matchNotRegexp = "!~"
+)
+
+var matcherRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*")\s*$`) //nolint:gochecknoglobals
+
+// labelMatcher matches the value of a single label.  Series without the label
+// are matched against the empty string.  The __name__ label matches the name
+// of the metric family.
+type labelMatcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func parseMatcher(s string) (*labelMatcher, error) {
+	parts := matcherRegexp.FindStringSubmatch(s)
+	if parts == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, s)
+	}
+
+	value, err := strconv.Unquote(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, s)
+	}
+
+	m := &labelMatcher{
+		name:  parts[1],
+		op:    parts[2],
+		value: value,
+	}
+
+	if m.op == matchRegexp || m.op == matchNotRegexp {
+		// Regular expressions are fully anchored.
+		m.re, err = regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidMatcher, s, err)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *labelMatcher) matches(v string) bool {
+	switch m.op {
+	case matchEqual:
+		return v == m.value
+	case matchNotEqual:
+		return v != m.value
+	case matchRegexp:
+		return m.re.MatchString(v)
+	case matchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// familyFilter selects the metric families and series that are encoded by the
+// handler.
+type familyFilter struct {
+	names    map[string]bool
+	matchers []*labelMatcher
+	exclude  map[string]bool
+}
+
+// newFamilyFilter creates the filter from the request query parameters.  The
+// excluded families are left out unless they are explicitly requested by name.
+func newFamilyFilter(r *http.Request, exclude []string) (*familyFilter, error) {
+	query := r.URL.Query()
+	f := &familyFilter{
+		names:   make(map[string]bool),
+		exclude: make(map[string]bool, len(exclude)),
+	}
+
+	for _, name := range query[NameParam] {
+		f.names[name] = true
+	}
+
+	for _, s := range query[MatchParam] {
+		m, err := parseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		f.matchers = append(f.matchers, m)
+	}
+
+	for _, name := range exclude {
+		f.exclude[name] = true
+	}
+
+	return f, nil
+}
+
+// apply returns the selected families.  The gathered families are not
+// modified so they can be shared between requests.
+func (f *familyFilter) apply(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	if len(f.names) == 0 && len(f.matchers) == 0 && len(f.exclude) == 0 {
+		return mfs
+	}
+
+	out := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		name := mf.GetName()
+		if len(f.names) > 0 && !f.names[name] {
+			continue
+		}
+		if len(f.names) == 0 && f.exclude[name] {
+			continue
+		}
+
+		if len(f.matchers) == 0 {
+			out = append(out, mf)
+			continue
+		}
+
+		metrics := make([]*dto.Metric, 0, len(mf.GetMetric()))
+		for _, metric := range mf.GetMetric() {
+			if f.matches(name, metric) {
+				metrics = append(metrics, metric)
+			}
+		}
+
+		if len(metrics) > 0 {
+			out = append(out, &dto.MetricFamily{
+				Name:   mf.Name,
+				Help:   mf.Help,
+				Type:   mf.Type,
+				Unit:   mf.Unit,
+				Metric: metrics,
+			})
+		}
+	}
+
+	return out
+}
+
+func (f *familyFilter) matches(name string, metric *dto.Metric) bool {
+	for _, m := range f.matchers {
+		v := name
+		if m.name != "__name__" {
+			v = labelValue(metric, m.name)
+		}
+
+		if !m.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, lp := range metric.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package strata
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMatcher(t *testing.T) {
+	tests := []struct {
+		matcher string
+		value   string
+		match   bool
+	}{
+		{`code="200"`, "200", true},
+		{`code="200"`, "500", false},
+		{`code!="200"`, "500", true},
+		{`code=~"5.."`, "503", true},
+		{`code=~"5.."`, "5030", false},
+		{`code!~"5.."`, "200", true},
+		{` code = "a\"b" `, `a"b`, true},
+		{`code=""`, "", true},
+	}
+
+	for _, tt := range tests {
+		m, err := parseMatcher(tt.matcher)
+		require.NoError(t, err, tt.matcher)
+		assert.Equal(t, tt.match, m.matches(tt.value), tt.matcher)
+	}
+
+	for _, invalid := range []string{`code`, `code=200`, `0code="200"`, `code=~"("`, `code=="200"`} {
+		_, err := parseMatcher(invalid)
+		assert.ErrorIs(t, err, ErrInvalidMatcher, invalid)
+	}
+}
+
+func TestHandlerFilter(t *testing.T) {
+	m := New(MetricsOpts{
+		Registry:     prometheus.NewRegistry(),
+		PanicOnError: true,
+		Collectors:   &CollectorsOpts{DisableGoCollector: true, DisableProcessCollector: true},
+	})
+	m.WithLabels("code").CounterInc("requests_total", "200")
+	m.WithLabels("code").CounterInc("requests_total", "503")
+	m.GaugeSet("queue_depth", 3)
+	m.GaugeSet("debug_cache_entries", 42)
+
+	h := HandlerWithOpts(m, HandlerOpts{ExcludeFamilies: []string{"debug_cache_entries"}})
+
+	scrape := func(query url.Values) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics?"+query.Encode(), nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	code, body := scrape(url.Values{})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "requests_total")
+	assert.Contains(t, body, "queue_depth 3")
+	assert.NotContains(t, body, "debug_cache_entries")
+
+	_, body = scrape(url.Values{NameParam: {"queue_depth", "debug_cache_entries"}})
+	assert.NotContains(t, body, "requests_total")
+	assert.Contains(t, body, "queue_depth 3")
+	assert.Contains(t, body, "debug_cache_entries 42")
+
+	_, body = scrape(url.Values{MatchParam: {`code=~"5.."`}})
+	assert.Contains(t, body, `requests_total{code="503"} 1`)
+	assert.NotContains(t, body, `requests_total{code="200"}`)
+	assert.NotContains(t, body, "queue_depth")
+
+	_, body = scrape(url.Values{MatchParam: {`__name__="queue_depth"`}})
+	assert.NotContains(t, body, "requests_total")
+	assert.Contains(t, body, "queue_depth 3")
+
+	code, _ = scrape(url.Values{MatchParam: {`code=5..`}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
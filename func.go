@@ -0,0 +1,235 @@
+package strata
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelValueSeparator separates the label values in the keys returned by the
+// callbacks of the labeled func metrics.  It can't be part of a valid UTF-8
+// label value.
+const labelValueSeparator = "\xff"
+
+// LabelValues returns the key of a series with the label values in the maps
+// returned by the callbacks of GaugeFuncVec and CounterFuncVec.  The values
+// are passed in the order of the labels.  With a single label the key is the
+// label value itself.
+func LabelValues(lv ...string) string {
+	return strings.Join(lv, labelValueSeparator)
+}
+
+// GaugeFunc is a wrapper around the prometheus GaugeFunc.  The value of the
+// gauge is determined by calling the provided function at scrape time.  It is
+// useful for values such as queue lengths and pool statistics that are
+// already tracked elsewhere.
+type GaugeFunc struct {
+	name string
+	vec  prometheus.GaugeFunc
+}
+
+// NewGaugeFunc creates, registers, and returns a new GaugeFunc.
+func NewGaugeFunc(registerer prometheus.Registerer, name string, fn func() float64) (*GaugeFunc, error) {
+	return newGaugeFunc(registerer, name, DefaultHelpString, fn)
+}
+
+func newGaugeFunc(registerer prometheus.Registerer, name string, help string, fn func() float64) (*GaugeFunc, error) {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: name,
+		Help: help,
+	}, fn)
+
+	if err := Register(registerer, gauge); err != nil {
+		return nil, err
+	}
+
+	return &GaugeFunc{
+		name: name,
+		vec:  gauge,
+	}, nil
+}
+
+// Name returns the name of the GaugeFunc.
+func (g *GaugeFunc) Name() string {
+	return g.name
+}
+
+// Type returns the metric type.
+func (g *GaugeFunc) Type() MetricType {
+	return GaugeType
+}
+
+// Vec returns the prometheus GaugeFunc.
+func (g *GaugeFunc) Vec() prometheus.Collector {
+	return g.vec
+}
+
+// CounterFunc is a wrapper around the prometheus CounterFunc.  The value of
+// the counter is determined by calling the provided function at scrape time.
+// The function must return monotonically increasing values.
+type CounterFunc struct {
+	name string
+	vec  prometheus.CounterFunc
+}
+
+// NewCounterFunc creates, registers, and returns a new CounterFunc.
+func NewCounterFunc(registerer prometheus.Registerer, name string, fn func() float64) (*CounterFunc, error) {
+	return newCounterFunc(registerer, name, DefaultHelpString, fn)
+}
+
+func newCounterFunc(registerer prometheus.Registerer, name string, help string, fn func() float64) (*CounterFunc, error) {
+	counter := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, fn)
+
+	if err := Register(registerer, counter); err != nil {
+		return nil, err
+	}
+
+	return &CounterFunc{
+		name: name,
+		vec:  counter,
+	}, nil
+}
+
+// Name returns the name of the CounterFunc.
+func (c *CounterFunc) Name() string {
+	return c.name
+}
+
+// Type returns the metric type.
+func (c *CounterFunc) Type() MetricType {
+	return CounterType
+}
+
+// Vec returns the prometheus collector for the CounterFunc.
+func (c *CounterFunc) Vec() prometheus.Collector {
+	return c.vec
+}
+
+// GaugeFuncVec is a labeled variant of GaugeFunc.  The provided function is
+// called at scrape time and returns a map of label values, joined with
+// LabelValues, to gauge values.  One series is exposed for each entry in the
+// map.
+type GaugeFuncVec struct {
+	name   string
+	labels []string
+	vec    *funcVecCollector
+}
+
+// NewGaugeFuncVec creates, registers, and returns a new GaugeFuncVec.
+func NewGaugeFuncVec(registerer prometheus.Registerer, name string, labels []string, fn func() map[string]float64) (*GaugeFuncVec, error) {
+	return newGaugeFuncVec(registerer, name, DefaultHelpString, labels, fn)
+}
+
+func newGaugeFuncVec(registerer prometheus.Registerer, name string, help string, labels []string, fn func() map[string]float64) (*GaugeFuncVec, error) {
+	gauge := newFuncVecCollector(name, help, labels, prometheus.GaugeValue, fn)
+	if err := Register(registerer, gauge); err != nil {
+		return nil, err
+	}
+
+	return &GaugeFuncVec{
+		name:   name,
+		labels: labels,
+		vec:    gauge,
+	}, nil
+}
+
+// Name returns the name of the GaugeFuncVec.
+func (g *GaugeFuncVec) Name() string {
+	return g.name
+}
+
+// Type returns the metric type.
+func (g *GaugeFuncVec) Type() MetricType {
+	return GaugeType
+}
+
+// Vec returns the prometheus collector for the GaugeFuncVec.
+func (g *GaugeFuncVec) Vec() prometheus.Collector {
+	return g.vec
+}
+
+// CounterFuncVec is a labeled variant of CounterFunc.  The provided function
+// is called at scrape time and returns a map of label values, joined with
+// LabelValues, to counter values.  One series is exposed for each entry in
+// the map.
+type CounterFuncVec struct {
+	name   string
+	labels []string
+	vec    *funcVecCollector
+}
+
+// NewCounterFuncVec creates, registers, and returns a new CounterFuncVec.
+func NewCounterFuncVec(registerer prometheus.Registerer, name string, labels []string, fn func() map[string]float64) (*CounterFuncVec, error) {
+	return newCounterFuncVec(registerer, name, DefaultHelpString, labels, fn)
+}
+
+func newCounterFuncVec(registerer prometheus.Registerer, name string, help string, labels []string, fn func() map[string]float64) (*CounterFuncVec, error) {
+	counter := newFuncVecCollector(name, help, labels, prometheus.CounterValue, fn)
+	if err := Register(registerer, counter); err != nil {
+		return nil, err
+	}
+
+	return &CounterFuncVec{
+		name:   name,
+		labels: labels,
+		vec:    counter,
+	}, nil
+}
+
+// Name returns the name of the CounterFuncVec.
+func (c *CounterFuncVec) Name() string {
+	return c.name
+}
+
+// Type returns the metric type.
+func (c *CounterFuncVec) Type() MetricType {
+	return CounterType
+}
+
+// Vec returns the prometheus collector for the CounterFuncVec.
+func (c *CounterFuncVec) Vec() prometheus.Collector {
+	return c.vec
+}
+
+// funcVecCollector is a prometheus collector that evaluates a function at
+// collection time and emits one constant metric per returned key of label
+// values.
+type funcVecCollector struct {
+	desc  *prometheus.Desc
+	vtype prometheus.ValueType
+	fn    func() map[string]float64
+}
+
+func newFuncVecCollector(name string, help string, labels []string, vtype prometheus.ValueType, fn func() map[string]float64) *funcVecCollector {
+	return &funcVecCollector{
+		desc:  prometheus.NewDesc(name, help, labels, nil),
+		vtype: vtype,
+		fn:    fn,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *funcVecCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *funcVecCollector) Collect(ch chan<- prometheus.Metric) {
+	for key, v := range c.fn() {
+		metric, err := prometheus.NewConstMetric(c.desc, c.vtype, v, strings.Split(key, labelValueSeparator)...)
+		if err != nil {
+			metric = prometheus.NewInvalidMetric(c.desc, err)
+		}
+		ch <- metric
+	}
+}
+
+var (
+	_ MetricVec = &GaugeFunc{}
+	_ MetricVec = &CounterFunc{}
+	_ MetricVec = &GaugeFuncVec{}
+	_ MetricVec = &CounterFuncVec{}
+)
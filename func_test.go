@@ -0,0 +1,86 @@
+package strata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	value := 1.0
+	reg := prometheus.NewPedanticRegistry()
+	vec, err := NewGaugeFunc(reg, "test_gf", func() float64 { return value })
+	assert.NoError(t, err)
+	CollectAndCompare(t, vec, "test_gf", "gauge", nil, 1.0)
+
+	value = 5.0
+	CollectAndCompare(t, vec, "test_gf", "gauge", nil, 5.0)
+}
+
+func TestCounterFunc(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	vec, err := NewCounterFunc(reg, "test_cf_total", func() float64 { return 3.0 })
+	assert.NoError(t, err)
+	CollectAndCompare(t, vec, "test_cf_total", "counter", nil, 3.0)
+}
+
+func TestGaugeFuncVec(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	vec, err := NewGaugeFuncVec(reg, "test_gfv", []string{"state"}, func() map[string]float64 {
+		return map[string]float64{"idle": 2.0, "inuse": 3.0}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, GaugeType, vec.Type())
+
+	expected := `# HELP test_gfv created automagically by strata
+# TYPE test_gfv gauge
+test_gfv{state="idle"} 2
+test_gfv{state="inuse"} 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(vec.Vec(), strings.NewReader(expected)))
+}
+
+func TestCounterFuncVecLabels(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	vec, err := NewCounterFuncVec(reg, "test_cfv_total", []string{"pool", "state"}, func() map[string]float64 {
+		return map[string]float64{
+			LabelValues("primary", "idle"):  2.0,
+			LabelValues("replica", "inuse"): 3.0,
+		}
+	})
+	assert.NoError(t, err)
+
+	expected := `# HELP test_cfv_total created automagically by strata
+# TYPE test_cfv_total counter
+test_cfv_total{pool="primary",state="idle"} 2
+test_cfv_total{pool="replica",state="inuse"} 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(vec.Vec(), strings.NewReader(expected)))
+
+	// A key with the wrong number of label values is reported as an error.
+	invalid, err := NewCounterFuncVec(prometheus.NewRegistry(), "test_invalid_total", []string{"pool", "state"}, func() map[string]float64 {
+		return map[string]float64{"primary": 1.0}
+	})
+	assert.NoError(t, err)
+	assert.Error(t, testutil.CollectAndCompare(invalid.Vec(), strings.NewReader("")))
+}
+
+func TestMetricsFuncReregister(t *testing.T) {
+	m := testMetrics()
+	m.GaugeFunc("test_gf", func() float64 { return 1.0 })
+	m.CounterFuncVec("test_cfv_total", []string{"state"}, func() map[string]float64 { return nil })
+
+	assert.PanicsWithValue(t, ErrAlreadyRegistered, func() {
+		m.GaugeFunc("test_gf", func() float64 { return 2.0 })
+	})
+	assert.PanicsWithValue(t, ErrAlreadyRegistered, func() {
+		m.CounterFunc("test_cfv_total", func() float64 { return 2.0 })
+	})
+
+	vec, ok := m.store.funcs["strata_example_test_gf"]
+	assert.True(t, ok)
+	CollectAndCompare(t, vec, "strata_example_test_gf", "gauge", nil, 1.0)
+}
@@ -0,0 +1,73 @@
+package strata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// cachingGatherer shares the result of a gather between the scrapes that
+// arrive within the ttl.  Concurrent scrapes wait for the gather that is in
+// progress instead of running the collectors again.  Failed gathers are not
+// cached.
+type cachingGatherer struct {
+	gatherer prometheus.Gatherer
+	ttl      time.Duration
+	now      func() time.Time
+	mfs      []*dto.MetricFamily
+	expires  time.Time
+	call     *gatherCall
+	sync.Mutex
+}
+
+// gatherCall is a gather that is in progress.
+type gatherCall struct {
+	done chan struct{}
+	mfs  []*dto.MetricFamily
+	err  error
+}
+
+func newCachingGatherer(gatherer prometheus.Gatherer, ttl time.Duration) *cachingGatherer {
+	return &cachingGatherer{
+		gatherer: gatherer,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// Gather implements prometheus.Gatherer.  The returned families are shared and
+// must not be modified.
+func (c *cachingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	c.Lock()
+	if c.mfs != nil && c.now().Before(c.expires) {
+		mfs := c.mfs
+		c.Unlock()
+		return mfs, nil
+	}
+
+	if call := c.call; call != nil {
+		c.Unlock()
+		<-call.done
+		return call.mfs, call.err
+	}
+
+	call := &gatherCall{done: make(chan struct{})}
+	c.call = call
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		if call.err == nil {
+			c.mfs = call.mfs
+			c.expires = c.now().Add(c.ttl)
+		}
+		c.call = nil
+		c.Unlock()
+		close(call.done)
+	}()
+
+	call.mfs, call.err = c.gatherer.Gather()
+	return call.mfs, call.err
+}
@@ -0,0 +1,97 @@
+package strata
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingGatherer struct {
+	calls   atomic.Int32
+	block   chan struct{}
+	started chan struct{}
+	err     error
+}
+
+func (g *countingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.calls.Add(1)
+	if g.started != nil {
+		g.started <- struct{}{}
+	}
+	if g.block != nil {
+		<-g.block
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	name := "test"
+	return []*dto.MetricFamily{{Name: &name}}, nil
+}
+
+func TestCachingGatherer(t *testing.T) {
+	g := &countingGatherer{}
+	c := newCachingGatherer(g, time.Second)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		mfs, err := c.Gather()
+		require.NoError(t, err)
+		assert.Len(t, mfs, 1)
+	}
+	assert.Equal(t, int32(1), g.calls.Load())
+
+	now = now.Add(time.Second)
+	_, err := c.Gather()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), g.calls.Load())
+}
+
+func TestCachingGathererSharesInFlightGather(t *testing.T) {
+	g := &countingGatherer{
+		block:   make(chan struct{}),
+		started: make(chan struct{}, 10),
+	}
+	c := newCachingGatherer(g, time.Second)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = c.Gather()
+	}()
+	<-g.started
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mfs, err := c.Gather()
+			assert.NoError(t, err)
+			assert.Len(t, mfs, 1)
+		}()
+	}
+
+	// Wait until the scrapes are queued behind the gather in progress.
+	time.Sleep(50 * time.Millisecond)
+	close(g.block)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), g.calls.Load())
+}
+
+func TestCachingGathererDoesNotCacheErrors(t *testing.T) {
+	g := &countingGatherer{err: assert.AnError}
+	c := newCachingGatherer(g, time.Minute)
+
+	_, err := c.Gather()
+	assert.ErrorIs(t, err, assert.AnError)
+	_, err = c.Gather()
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, int32(2), g.calls.Load())
+}
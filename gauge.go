@@ -34,15 +34,20 @@ import "github.com/prometheus/client_golang/prometheus"
 // are often used to represent things like disk and memory usage and concurrent
 // requests.
 type GaugeVec struct {
-	name string
-	vec  *prometheus.GaugeVec
+	name   string
+	vec    *prometheus.GaugeVec
+	labels []string
 }
 
 // NewGaugeVec creates, registers, and returns a new GaugeVec.
 func NewGaugeVec(registerer prometheus.Registerer, name string, labels ...string) (*GaugeVec, error) {
+	return newGaugeVec(registerer, name, DefaultHelpString, labels...)
+}
+
+func newGaugeVec(registerer prometheus.Registerer, name string, help string, labels ...string) (*GaugeVec, error) {
 	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: name,
-		Help: DefaultHelpString,
+		Help: help,
 	}, labels)
 
 	if err := Register(registerer, gauge); err != nil {
@@ -50,8 +55,9 @@ func NewGaugeVec(registerer prometheus.Registerer, name string, labels ...string
 	}
 
 	return &GaugeVec{
-		name: name,
-		vec:  gauge,
+		name:   name,
+		vec:    gauge,
+		labels: labels,
 	}, nil
 }
 
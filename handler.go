@@ -1,14 +1,300 @@
 package strata
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
+// HandlerOpts defines options that are available to the metrics handler.
+type HandlerOpts struct {
+	// EnableOpenMetrics enables the negotiation of the OpenMetrics exposition
+	// format.  When OpenMetrics is negotiated, info and stateset metrics are
+	// exposed with their native types.
+	EnableOpenMetrics bool
+	// ExcludeFamilies is a list of metric family names that are left out of
+	// the response unless they are explicitly requested with the name[] query
+	// parameter.
+	ExcludeFamilies []string
+	// MaxRequestsInFlight limits the number of concurrent scrapes that are
+	// served.  Scrapes that exceed the limit receive a 503 response.  By
+	// default the number of scrapes is not limited.
+	MaxRequestsInFlight int
+	// GzipLevel is the compression level used for gzip encoded responses.
+	// The levels of compress/gzip are supported.  By default
+	// gzip.DefaultCompression is used.
+	GzipLevel int
+	// ZstdLevel is the compression level used for zstd encoded responses
+	// using the levels of the zstd command line tool.  By default the fastest
+	// level is used.
+	ZstdLevel int
+	// GatherCacheTTL shares the gathered metrics between the scrapes that
+	// arrive within the duration.  Concurrent scrapes wait for the gather that
+	// is in progress instead of running the collectors again.  By default the
+	// metrics are gathered for every scrape.
+	GatherCacheTTL time.Duration
+	// Endpoint is the value of the endpoint label of the scrape metrics.  By
+	// default /metrics is used.
+	Endpoint string
+}
+
 // HandlerFor returns the handler for the metrics registry.
 func HandlerFor(metrics *Metrics) http.Handler {
-	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{
-		Timeout: DefaultTimeout,
-	})
+	return HandlerWithOpts(metrics, HandlerOpts{})
+}
+
+// HandlerWithOpts returns the handler for the metrics registry using the
+// provided options.  The scrapes served by the handler are recorded in the
+// strata_scrape_* metrics which are registered with the metrics registry.
+func HandlerWithOpts(metrics *Metrics, opts HandlerOpts) http.Handler {
+	if opts.Endpoint == "" {
+		opts.Endpoint = "/metrics"
+	}
+	scrape := newScrapeMetrics(metrics.registerer).observer(opts.Endpoint)
+	return newHandler(metrics.registry, metrics.store, opts, scrape)
+}
+
+// handler gathers and encodes the metric families from a gatherer.  It replaces
+// promhttp.HandlerFor so the native OpenMetrics types that can't be expressed
+// by the prometheus client model can be exposed.
+type handler struct {
+	gatherer prometheus.Gatherer
+	store    *Store
+	opts     HandlerOpts
+	scrape   *scrapeObserver
+	inFlight chan struct{}
+}
+
+func newHandler(gatherer prometheus.Gatherer, store *Store, opts HandlerOpts, scrape *scrapeObserver) http.Handler {
+	h := &handler{
+		gatherer: gatherer,
+		store:    store,
+		opts:     opts,
+		scrape:   scrape,
+	}
+
+	if opts.GatherCacheTTL > 0 {
+		h.gatherer = newCachingGatherer(gatherer, opts.GatherCacheTTL)
+	}
+
+	if opts.MaxRequestsInFlight > 0 {
+		h.inFlight = make(chan struct{}, opts.MaxRequestsInFlight)
+	}
+
+	return http.TimeoutHandler(h, DefaultTimeout, fmt.Sprintf(
+		"Exceeded configured timeout of %v.\n",
+		DefaultTimeout,
+	))
+}
+
+// ServeHTTP implements http.Handler.  The families can be selected with the
+// name[] and match[] query parameters, for example:
+//
+//	/metrics?name[]=http_requests_total&match[]=code=~"5.."
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.acquire() {
+		h.scrape.error(scrapeErrorLimit)
+		http.Error(w, fmt.Sprintf(
+			"Limit of concurrent requests reached (%d), try again later.\n",
+			h.opts.MaxRequestsInFlight,
+		), http.StatusServiceUnavailable)
+		return
+	}
+	defer h.release()
+
+	counter := &countingWriter{w: w}
+	done := h.scrape.start()
+	defer func() {
+		done(counter.n)
+	}()
+
+	filter, err := newFamilyFilter(r, h.opts.ExcludeFamilies)
+	if err != nil {
+		h.scrape.error(scrapeErrorBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mfs, err := h.gatherer.Gather()
+	if err != nil {
+		h.scrape.error(scrapeErrorGather)
+		httpError(w, err)
+		return
+	}
+	mfs = filter.apply(mfs)
+
+	format := h.negotiate(r)
+	body, err := h.encode(mfs, format)
+	if err != nil {
+		h.scrape.error(scrapeErrorEncode)
+		httpError(w, err)
+		return
+	}
+
+	if r.Context().Err() != nil {
+		// The timeout handler has already responded.
+		h.scrape.error(scrapeErrorTimeout)
+		return
+	}
+
+	w.Header().Set("Content-Type", string(format))
+	cw, encoding, closer := h.compressedWriter(r, counter)
+	defer closer()
+
+	if encoding != "identity" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if _, err := cw.Write(body); err != nil {
+		h.scrape.error(scrapeErrorWrite)
+	}
+}
+
+// acquire reserves a slot for the request, returning false if the limit of
+// concurrent requests has been reached.
+func (h *handler) acquire() bool {
+	if h.inFlight == nil {
+		return true
+	}
+
+	select {
+	case h.inFlight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *handler) release() {
+	if h.inFlight != nil {
+		<-h.inFlight
+	}
+}
+
+func (h *handler) negotiate(r *http.Request) expfmt.Format {
+	if h.opts.EnableOpenMetrics {
+		return expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	}
+	return expfmt.Negotiate(r.Header)
+}
+
+func (h *handler) encode(mfs []*dto.MetricFamily, format expfmt.Format) ([]byte, error) {
+	var types map[string]MetricType
+	if h.store != nil && format.FormatType() == expfmt.TypeOpenMetrics {
+		types = h.store.openMetricsTypes()
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, format)
+	for _, mf := range mfs {
+		if mtype, ok := types[mf.GetName()]; ok {
+			if err := encodeNative(&buf, mf, mtype); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := enc.Encode(mf); err != nil {
+			return nil, err
+		}
+	}
+
+	if closer, ok := enc.(expfmt.Closer); ok {
+		// Writes the final "# EOF" line for OpenMetrics.
+		if err := closer.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeNative encodes the metric family using the OpenMetrics encoder and
+// rewrites the metadata to use the native type.  Info families drop the _info
+// suffix from the family name while the samples keep it.
+func encodeNative(w io.Writer, mf *dto.MetricFamily, mtype MetricType) error {
+	var buf bytes.Buffer
+	if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, mf); err != nil {
+		return err
+	}
+
+	name := mf.GetName()
+	family := name
+	if mtype == InfoType {
+		family = strings.TrimSuffix(name, "_info")
+	}
+
+	out := strings.Replace(buf.String(), "# HELP "+name+" ", "# HELP "+family+" ", 1)
+	out = strings.Replace(out, "# TYPE "+name+" gauge\n", "# TYPE "+family+" "+string(mtype)+"\n", 1)
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// compressedWriter selects the first encoding from the Accept-Encoding header
+// that is supported and returns a writer that compresses the response.
+func (h *handler) compressedWriter(r *http.Request, w io.Writer) (io.Writer, string, func()) {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if !acceptable(params) {
+			continue
+		}
+
+		switch strings.TrimSpace(name) {
+		case "gzip":
+			gz, err := gzip.NewWriterLevel(w, h.gzipLevel())
+			if err != nil {
+				continue
+			}
+			return gz, "gzip", func() { _ = gz.Close() }
+		case "zstd":
+			z, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(h.opts.ZstdLevel)))
+			if err != nil {
+				continue
+			}
+			return z, "zstd", func() { _ = z.Close() }
+		}
+	}
+
+	return w, "identity", func() {}
+}
+
+// acceptable returns false if the parameters of a content coding have a
+// q-value of 0, which refuses the coding, or an invalid q-value.
+func acceptable(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *handler) gzipLevel() int {
+	if h.opts.GzipLevel == 0 {
+		return gzip.DefaultCompression
+	}
+	return h.opts.GzipLevel
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	http.Error(
+		w,
+		"An error has occurred while serving metrics:\n\n"+err.Error(),
+		http.StatusInternalServerError,
+	)
 }
@@ -0,0 +1,105 @@
+package strata
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerOpenMetricsNativeTypes(t *testing.T) {
+	m := New(MetricsOpts{
+		Registry:     prometheus.NewPedanticRegistry(),
+		PanicOnError: true,
+	})
+	m.Info("build", map[string]string{"version": "v1.0.0"})
+	_ = m.StateSet("breaker", []string{"closed", "open"}).SetState("open")
+
+	h := HandlerWithOpts(m, HandlerOpts{EnableOpenMetrics: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.Contains(t, string(body), `# HELP breaker created automagically by strata
+# TYPE breaker stateset
+breaker{breaker="closed"} 0.0
+breaker{breaker="open"} 1.0
+# HELP build created automagically by strata
+# TYPE build info
+build_info{version="v1.0.0"} 1.0
+`)
+	assert.Contains(t, string(body), "# EOF\n")
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body, _ = io.ReadAll(rec.Body)
+
+	assert.Contains(t, string(body), "# TYPE build_info gauge\n")
+	assert.Contains(t, string(body), "# TYPE breaker gauge\n")
+}
+
+func TestHandlerCompressionLevels(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true})
+	m.GaugeSet("queue_depth", 3)
+
+	h := HandlerWithOpts(m, HandlerOpts{GzipLevel: gzip.BestCompression, ZstdLevel: 19})
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "queue_depth 3")
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+	z, err := zstd.NewReader(rec.Body)
+	require.NoError(t, err)
+	defer z.Close()
+	body, err = io.ReadAll(z)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "queue_depth 3")
+
+	// Codings with a q-value of 0 are refused.
+	for header, encoding := range map[string]string{
+		"gzip;q=0.0, zstd":     "zstd",
+		"zstd; q=0, gzip;q=1":  "gzip",
+		"gzip;Q=0.000":         "",
+		"gzip;q=invalid":       "",
+		"zstd;q=0.5, gzip;q=0": "zstd",
+	} {
+		req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept-Encoding", header)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, encoding, rec.Header().Get("Content-Encoding"), header)
+	}
+}
+
+func TestServerInvalidGzipLevel(t *testing.T) {
+	m := New(MetricsOpts{})
+	err := m.Start(context.Background(), ServerOpts{
+		BindAddr:      "127.0.0.1",
+		EphemeralPort: true,
+		GzipLevel:     gzip.BestCompression + 1,
+	})
+	assert.Error(t, err)
+}
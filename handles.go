@@ -0,0 +1,115 @@
+package strata
+
+// Counter is a counter bound to a name and label values.  It is created with
+// Metrics.Counter and is used by the accessors generated by strata-gen so the
+// label values are checked at compile time.
+type Counter struct {
+	metrics *Metrics
+	name    string
+	lv      []string
+}
+
+// Counter returns the counter with the name and label values.  The label
+// values are passed in the order the labels were defined with WithLabels.
+func (m *Metrics) Counter(name string, lv ...string) *Counter {
+	return &Counter{metrics: m, name: name, lv: lv}
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc() {
+	c.metrics.CounterInc(c.name, c.lv...)
+}
+
+// Add increases the counter by the given value.
+func (c *Counter) Add(v float64) {
+	c.metrics.CounterAdd(c.name, v, c.lv...)
+}
+
+// Gauge is a gauge bound to a name and label values.  It is created with
+// Metrics.Gauge.
+type Gauge struct {
+	metrics *Metrics
+	name    string
+	lv      []string
+}
+
+// Gauge returns the gauge with the name and label values.  The label values
+// are passed in the order the labels were defined with WithLabels.
+func (m *Metrics) Gauge(name string, lv ...string) *Gauge {
+	return &Gauge{metrics: m, name: name, lv: lv}
+}
+
+// Set sets the gauge to the given value.
+func (g *Gauge) Set(v float64) {
+	g.metrics.GaugeSet(g.name, v, g.lv...)
+}
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc() {
+	g.metrics.GaugeInc(g.name, g.lv...)
+}
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec() {
+	g.metrics.GaugeDec(g.name, g.lv...)
+}
+
+// Add increases the gauge by the given value.
+func (g *Gauge) Add(v float64) {
+	g.metrics.GaugeAdd(g.name, v, g.lv...)
+}
+
+// Sub decreases the gauge by the given value.
+func (g *Gauge) Sub(v float64) {
+	g.metrics.GaugeSub(g.name, v, g.lv...)
+}
+
+// Histogram is a histogram bound to a name and label values.  It is created
+// with Metrics.Histogram.
+type Histogram struct {
+	metrics *Metrics
+	name    string
+	lv      []string
+}
+
+// Histogram returns the histogram with the name and label values.  The label
+// values are passed in the order the labels were defined with WithLabels.
+func (m *Metrics) Histogram(name string, lv ...string) *Histogram {
+	return &Histogram{metrics: m, name: name, lv: lv}
+}
+
+// Observe adds a single observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.metrics.HistogramObserve(h.name, v, h.lv...)
+}
+
+// Timer returns a timer that observes the elapsed time in seconds when
+// ObserveDuration is called.
+func (h *Histogram) Timer() *Timer {
+	return h.metrics.HistogramTimer(h.name, h.lv...)
+}
+
+// Summary is a summary bound to a name and label values.  It is created with
+// Metrics.Summary.
+type Summary struct {
+	metrics *Metrics
+	name    string
+	lv      []string
+}
+
+// Summary returns the summary with the name and label values.  The label
+// values are passed in the order the labels were defined with WithLabels.
+func (m *Metrics) Summary(name string, lv ...string) *Summary {
+	return &Summary{metrics: m, name: name, lv: lv}
+}
+
+// Observe adds a single observation to the summary.
+func (s *Summary) Observe(v float64) {
+	s.metrics.SummaryObserve(s.name, v, s.lv...)
+}
+
+// Timer returns a timer that observes the elapsed time in seconds when
+// ObserveDuration is called.
+func (s *Summary) Timer() *Timer {
+	return s.metrics.SummaryTimer(s.name, s.lv...)
+}
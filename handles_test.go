@@ -0,0 +1,35 @@
+package strata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandles(t *testing.T) {
+	rec := NewRecorder()
+	m := New(MetricsOpts{Recorder: rec})
+
+	requests := m.WithLabels("code").Counter("requests_total", "200")
+	requests.Inc()
+	requests.Add(2)
+	assert.Equal(t, 3.0, rec.Value("requests_total", map[string]string{"code": "200"}))
+
+	depth := m.Gauge("queue_depth")
+	depth.Set(5)
+	depth.Inc()
+	depth.Dec()
+	depth.Add(3)
+	depth.Sub(1)
+	assert.Equal(t, 7.0, rec.Value("queue_depth", nil))
+
+	latency := m.WithLabels("method").Histogram("latency_seconds", "GET")
+	latency.Observe(0.5)
+	latency.Timer().ObserveDuration()
+	assert.Len(t, rec.Find("latency_seconds"), 2)
+
+	size := m.Summary("size_bytes")
+	size.Observe(1024)
+	size.Timer().ObserveDuration()
+	assert.Len(t, rec.Find("size_bytes"), 2)
+}
@@ -0,0 +1,179 @@
+package strata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// HealthzPath is the path of the endpoint that runs all of the checks.
+	HealthzPath = "/healthz"
+	// ReadyzPath is the path of the endpoint that runs the readiness checks.
+	ReadyzPath = "/readyz"
+	// LivezPath is the path of the endpoint that runs the liveness checks.
+	LivezPath = "/livez"
+
+	// healthCheckStatusName is the name of the gauge that exports the
+	// outcome of the checks.
+	healthCheckStatusName = "strata_health_check_status"
+
+	readinessCheck = "readiness"
+	livenessCheck  = "liveness"
+)
+
+// CheckFunc is a health check function.  A nil error reports the check as
+// passing.
+type CheckFunc func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	ctype string
+	fn    CheckFunc
+}
+
+// healthChecks holds the readiness and liveness checks.  It is shared between
+// a Metrics and all of the Metrics derived from it.
+type healthChecks struct {
+	checks  []namedCheck
+	metrics *Metrics
+	sync.RWMutex
+}
+
+func newHealthChecks(metrics *Metrics) *healthChecks {
+	return &healthChecks{
+		checks:  make([]namedCheck, 0),
+		metrics: metrics,
+	}
+}
+
+// AddReadinessCheck registers a named check that is run by the /readyz and
+// /healthz endpoints of the built-in server.  A check with the same name
+// replaces the existing check.  Example:
+//
+//	m.AddReadinessCheck("database", func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	})
+func (m *Metrics) AddReadinessCheck(name string, fn func(ctx context.Context) error) {
+	m.health.add(name, readinessCheck, fn)
+}
+
+// AddLivenessCheck registers a named check that is run by the /livez and
+// /healthz endpoints of the built-in server.  A check with the same name
+// replaces the existing check.
+func (m *Metrics) AddLivenessCheck(name string, fn func(ctx context.Context) error) {
+	m.health.add(name, livenessCheck, fn)
+}
+
+func (h *healthChecks) add(name string, ctype string, fn CheckFunc) {
+	h.Lock()
+	defer h.Unlock()
+
+	for i, c := range h.checks {
+		if c.name == name && c.ctype == ctype {
+			h.checks[i].fn = fn
+			return
+		}
+	}
+
+	h.checks = append(h.checks, namedCheck{name: name, ctype: ctype, fn: fn})
+}
+
+// mount adds the health endpoints to the mux.
+func (h *healthChecks) mount(mux *http.ServeMux) {
+	mux.Handle(HealthzPath, h.handler("healthz"))
+	mux.Handle(ReadyzPath, h.handler("readyz", readinessCheck))
+	mux.Handle(LivezPath, h.handler("livez", livenessCheck))
+}
+
+// handler runs the checks of the provided types, or all of the checks if no
+// types are provided.  The verbose query parameter lists the result of each
+// check and the exclude query parameter skips checks by name.
+func (h *healthChecks) handler(endpoint string, ctypes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		excluded := make(map[string]bool)
+		for _, name := range r.URL.Query()["exclude"] {
+			excluded[name] = true
+		}
+
+		var out strings.Builder
+		failed := false
+		for _, c := range h.selected(ctypes...) {
+			if excluded[c.name] {
+				fmt.Fprintf(&out, "[+]%s excluded: ok\n", c.name)
+				continue
+			}
+
+			if err := h.run(r.Context(), c); err != nil {
+				failed = true
+				fmt.Fprintf(&out, "[-]%s failed: %s\n", c.name, err)
+				continue
+			}
+			fmt.Fprintf(&out, "[+]%s ok\n", c.name)
+		}
+
+		status, result := http.StatusOK, "passed"
+		if failed {
+			status, result = http.StatusServiceUnavailable, "failed"
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+
+		// Failures are always reported in full so the failing check is visible
+		// in probe events.
+		if _, verbose := r.URL.Query()["verbose"]; verbose || failed {
+			fmt.Fprintf(&out, "%s check %s\n", endpoint, result)
+			_, _ = w.Write([]byte(out.String()))
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	})
+}
+
+func (h *healthChecks) selected(ctypes ...string) []namedCheck {
+	h.RLock()
+	defer h.RUnlock()
+
+	checks := make([]namedCheck, 0, len(h.checks))
+	for _, c := range h.checks {
+		if len(ctypes) == 0 || contains(ctypes, c.ctype) {
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+// run executes the check and exports the outcome as the
+// strata_health_check_status gauge.
+func (h *healthChecks) run(ctx context.Context, c namedCheck) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+
+		var status float64
+		if err == nil {
+			status = 1
+		}
+		h.metrics.internal("check", "type").GaugeSet(healthCheckStatusName, status, c.name, c.ctype)
+	}()
+
+	return c.fn(ctx)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
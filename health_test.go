@@ -0,0 +1,70 @@
+package strata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func healthRequest(mux *http.ServeMux, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestHealthChecks(t *testing.T) {
+	m := New(MetricsOpts{
+		Registry:     prometheus.NewPedanticRegistry(),
+		PanicOnError: true,
+	}).WithPrefix("strata", "example")
+
+	var dbErr error
+	m.AddReadinessCheck("database", func(_ context.Context) error { return dbErr })
+	m.AddLivenessCheck("deadlock", func(_ context.Context) error { return nil })
+
+	mux := http.NewServeMux()
+	m.health.mount(mux)
+
+	rec := healthRequest(mux, "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+
+	rec = healthRequest(mux, "/healthz?verbose")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[+]database ok\n[+]deadlock ok\nhealthz check passed\n", rec.Body.String())
+
+	dbErr = errors.New("connection refused")
+	rec = healthRequest(mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "[-]database failed: connection refused\nreadyz check failed\n", rec.Body.String())
+
+	rec = healthRequest(mux, "/livez")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = healthRequest(mux, "/readyz?exclude=database")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	vec, ok := m.store.gauges["strata_health_check_status"]
+	assert.True(t, ok)
+	gauge := vec.vec
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge.WithLabelValues("database", "readiness")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge.WithLabelValues("deadlock", "liveness")))
+}
+
+func TestHealthCheckPanic(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewPedanticRegistry()})
+	m.AddReadinessCheck("broken", func(_ context.Context) error { panic("boom") })
+
+	mux := http.NewServeMux()
+	m.health.mount(mux)
+
+	rec := healthRequest(mux, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "[-]broken failed: check panicked: boom")
+}
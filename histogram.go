@@ -35,15 +35,20 @@ type HistogramOpts struct {
 // It bundles a set of histograms used if you want to count the same thing
 // partitioned by various dimensions.
 type HistogramVec struct {
-	name string
-	vec  *prometheus.HistogramVec
+	name   string
+	vec    *prometheus.HistogramVec
+	labels []string
 }
 
 // NewHistogramVec creates, registers, and returns a new HistogramVec.
 func NewHistogramVec(registerer prometheus.Registerer, name string, buckets []float64, labels ...string) (*HistogramVec, error) {
+	return newHistogramVec(registerer, name, DefaultHelpString, buckets, labels...)
+}
+
+func newHistogramVec(registerer prometheus.Registerer, name string, help string, buckets []float64, labels ...string) (*HistogramVec, error) {
 	summary := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    name,
-		Help:    DefaultHelpString,
+		Help:    help,
 		Buckets: buckets,
 	}, labels)
 
@@ -52,8 +57,9 @@ func NewHistogramVec(registerer prometheus.Registerer, name string, buckets []fl
 	}
 
 	return &HistogramVec{
-		name: name,
-		vec:  summary,
+		name:   name,
+		vec:    summary,
+		labels: labels,
 	}, nil
 }
 
@@ -0,0 +1,83 @@
+package strata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InfoVec is an info metric which exposes textual information such as build
+// or version information as labels on a series with a constant value of 1.
+// It is exposed as a gauge with an _info suffix, or with the native info type
+// when OpenMetrics is negotiated.
+type InfoVec struct {
+	name   string
+	labels []string
+	vec    *prometheus.GaugeVec
+}
+
+// NewInfoVec creates, registers, and returns a new InfoVec.  The _info suffix
+// is added to the name if it is not already present.
+func NewInfoVec(registerer prometheus.Registerer, name string, labels ...string) (*InfoVec, error) {
+	info := newInfoVec(name, labels...)
+	if err := Register(registerer, info.vec); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func newInfoVec(name string, labels ...string) *InfoVec {
+	name = infoName(name)
+	return &InfoVec{
+		name:   name,
+		labels: labels,
+		vec: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name,
+			Help: DefaultHelpString,
+		}, labels),
+	}
+}
+
+// Set replaces the information exposed by the InfoVec.  The keys of the
+// labels must match the labels that the InfoVec was created with.
+func (i *InfoVec) Set(labels map[string]string) error {
+	if len(labels) != len(i.labels) {
+		return fmt.Errorf("%w: expected %d labels but got %d", ErrInvalidLabelValues, len(i.labels), len(labels))
+	}
+
+	for _, l := range i.labels {
+		if _, ok := labels[l]; !ok {
+			return fmt.Errorf("%w: missing label %s", ErrInvalidLabelValues, l)
+		}
+	}
+
+	i.vec.Reset()
+	i.vec.With(prometheus.Labels(labels)).Set(1)
+	return nil
+}
+
+// Name returns the name of the InfoVec.
+func (i *InfoVec) Name() string {
+	return i.name
+}
+
+// Type returns the metric type.
+func (i *InfoVec) Type() MetricType {
+	return InfoType
+}
+
+// Vec returns the prometheus GaugeVec backing the InfoVec.
+func (i *InfoVec) Vec() prometheus.Collector {
+	return i.vec
+}
+
+func infoName(name string) string {
+	if strings.HasSuffix(name, "_info") {
+		return name
+	}
+	return name + "_info"
+}
+
+var _ MetricVec = &InfoVec{}
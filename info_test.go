@@ -0,0 +1,49 @@
+package strata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	vec, err := NewInfoVec(reg, "test_build", "revision", "version")
+	assert.NoError(t, err)
+	assert.Equal(t, "test_build_info", vec.Name())
+	assert.Equal(t, InfoType, vec.Type())
+
+	assert.NoError(t, vec.Set(map[string]string{"version": "v1.0.0", "revision": "abc"}))
+	assert.NoError(t, vec.Set(map[string]string{"version": "v1.0.1", "revision": "def"}))
+	assert.ErrorIs(t, vec.Set(map[string]string{"version": "v1.0.1"}), ErrInvalidLabelValues)
+
+	expected := `# HELP test_build_info created automagically by strata
+# TYPE test_build_info gauge
+test_build_info{revision="def",version="v1.0.1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(vec.Vec(), strings.NewReader(expected)))
+}
+
+func TestMetricsInfo(t *testing.T) {
+	m := testMetrics()
+	m.Info("build", map[string]string{"version": "v1.0.0"})
+
+	m.Info("build", map[string]string{"version": "v1.0.1"})
+
+	vec, ok := m.store.infos["strata_example_build_info"]
+	assert.True(t, ok)
+
+	expected := `# HELP strata_example_build_info created automagically by strata
+# TYPE strata_example_build_info gauge
+strata_example_build_info{version="v1.0.1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(vec.Vec(), strings.NewReader(expected)))
+	// The label names are fixed on first use.
+	assert.PanicsWithError(t, "inconsistent label cardinality: expected 1 labels but got 2", func() {
+		m.Info("build", map[string]string{"version": "v1.0.2", "revision": "abc"})
+	})
+	assert.NoError(t, testutil.CollectAndCompare(vec.Vec(), strings.NewReader(expected)))
+}
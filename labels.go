@@ -0,0 +1,93 @@
+package strata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`) //nolint:gochecknoglobals
+
+// validateLabelName returns an error if the name is not a valid prometheus
+// label name.  Names starting with __ are reserved for internal use.
+func validateLabelName(name string) error {
+	if !labelNameRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("invalid label name %q", name)
+	}
+	return nil
+}
+
+func validateLabelValue(name, value string) error {
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("value of label %q is not valid UTF-8", name)
+	}
+	return nil
+}
+
+// constantLabels merges and validates the ConstantLabels pairs and the Labels
+// map of the options.  A label may only be defined once.
+func constantLabels(opts MetricsOpts) (map[string]string, error) {
+	if len(opts.ConstantLabels)%2 != 0 {
+		return nil, fmt.Errorf(
+			"%w: ConstantLabels must contain label/value pairs, %q has no value",
+			ErrInvalidConstantLabels, opts.ConstantLabels[len(opts.ConstantLabels)-1],
+		)
+	}
+
+	labels := make(map[string]string, len(opts.ConstantLabels)/2+len(opts.Labels))
+	add := func(name, value string) error {
+		if err := validateLabelName(name); err != nil {
+			return err
+		}
+		if err := validateLabelValue(name, value); err != nil {
+			return err
+		}
+		if _, ok := labels[name]; ok {
+			return fmt.Errorf("label %q is defined more than once", name)
+		}
+		labels[name] = value
+		return nil
+	}
+
+	for i := 0; i < len(opts.ConstantLabels); i += 2 {
+		if err := add(opts.ConstantLabels[i], opts.ConstantLabels[i+1]); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidConstantLabels, err)
+		}
+	}
+
+	for _, name := range sortedKeys(opts.Labels) {
+		if err := add(name, opts.Labels[name]); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidConstantLabels, err)
+		}
+	}
+
+	return labels, nil
+}
+
+// checkLabelCollisions returns an error if a constant label is also used as a
+// variable label by a metric declared in the schema or by the build info
+// metric.  Prometheus would otherwise reject the collectors when they are
+// registered.
+func checkLabelCollisions(labels map[string]string, opts MetricsOpts) error {
+	if opts.Schema != nil {
+		for _, m := range opts.Schema.Metrics {
+			for _, l := range m.Labels {
+				if _, ok := labels[l]; ok {
+					return fmt.Errorf("%w: label %q of %s collides with a constant label", ErrInvalidConstantLabels, l, m.Name)
+				}
+			}
+		}
+	}
+
+	if opts.BuildInfo != nil {
+		// The keys are the same with or without the module build info.
+		for _, l := range sortedKeys(buildInfoLabels(nil, false, opts.BuildInfo.Labels)) {
+			if _, ok := labels[l]; ok {
+				return fmt.Errorf("%w: build info label %q collides with a constant label", ErrInvalidConstantLabels, l)
+			}
+		}
+	}
+
+	return nil
+}
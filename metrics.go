@@ -21,15 +21,13 @@ package strata
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net/http"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 type SummaryOpts struct {
@@ -49,6 +47,9 @@ type MetricsOpts struct {
 	// ConstantLabels is an array of label/value pairs that will be constant
 	// across all metrics.
 	ConstantLabels []string
+	// Labels are constant labels that will be added to all metrics.  They are
+	// merged with ConstantLabels, and a label may only be defined once.
+	Labels map[string]string
 	// HistogramBuckets are buckets used for histogram observation counts.
 	HistogramBuckets []float64
 	// SummaryOpts defines the options available to summary collectors.
@@ -71,6 +72,21 @@ type MetricsOpts struct {
 	// Logger takes a value that matches the Logger interface and is used for
 	// log output of errors and other debug information.
 	Logger Logger
+	// Collectors defines the options for the go runtime and process collectors
+	// that are registered with the Registry.
+	Collectors *CollectorsOpts
+	// BuildInfo registers a <prefix>_build_info metric populated from the build
+	// information embedded in the binary when set.
+	BuildInfo *BuildInfoOpts
+	// Schema declares the metrics that are exposed.  The declared metrics are
+	// registered when the metrics are created and use the declared help,
+	// buckets, objectives and cardinality limits.  In strict mode, metrics
+	// that are not declared are rejected.  See LoadSchema.
+	Schema *Schema
+	// Recorder replaces the prometheus collectors with an in-memory backend
+	// that records every operation as an Event.  When set, no collectors are
+	// registered with the Registry.
+	Recorder *Recorder
 }
 
 // Metrics provides a wrapper around the prometheus client to automatically
@@ -82,53 +98,142 @@ type Metrics struct {
 	summaryOpts      *SummaryOpts
 	store            *Store
 	labels           []string
+	constantLabels   map[string]string
 	errors           *ApexInternalErrorMetrics
 	panicOnError     bool
 	registry         *prometheus.Registry
 	registerer       prometheus.Registerer
-	server           *Server
+	server           *serverRef
+	health           *healthChecks
+	slis             *sliRegistry
+	slos             *sloRegistry
+	rates            *rateRegistry
 	logger           Logger
+	recorder         *Recorder
 }
 
 // New creates a new Apex metrics store using the options that have
-// been provided.
+// been provided.  New panics if the constant labels are invalid, use
+// NewWithError to handle the error instead.
 func New(opts MetricsOpts) *Metrics {
+	metrics, err := NewWithError(opts)
+	if err != nil {
+		panic(err)
+	}
+	return metrics
+}
+
+// NewWithError creates a new Apex metrics store using the options that have
+// been provided.  An error wrapping ErrInvalidConstantLabels is returned if the
+// constant labels are not valid label/value pairs, are defined more than once
+// or collide with the labels declared in the schema or the build info labels.
+func NewWithError(opts MetricsOpts) (*Metrics, error) {
 	opts = defaultedMetrics(opts)
 	prefix := strings.Join(opts.Prefix, string(opts.Separator))
-	labels := SlicePairsToMap(opts.ConstantLabels)
+	labels, err := constantLabels(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	_ = opts.Registry.Register(collectors.NewGoCollector())
-	_ = opts.Registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	if err := checkLabelCollisions(labels, opts); err != nil {
+		return nil, err
+	}
 
-	return &Metrics{
+	metrics := &Metrics{
 		prefix:           prefix,
 		separator:        opts.Separator,
 		histogramBuckets: opts.HistogramBuckets,
 		summaryOpts:      opts.SummaryOpts,
 		store:            newStore(),
 		labels:           []string{},
+		constantLabels:   labels,
 		panicOnError:     opts.PanicOnError,
 		errors:           NewApexInternalErrorMetrics(opts.Prefix, opts.Separator),
 		registry:         opts.Registry,
 		registerer:       prometheus.WrapRegistererWith(prometheus.Labels(labels), opts.Registry),
 		logger:           opts.Logger,
+		recorder:         opts.Recorder,
+		server:           newServerRef(),
+		slis:             &sliRegistry{},
+		slos:             newSLORegistry(),
+		rates:            newRateRegistry(),
 	}
+
+	metrics.health = newHealthChecks(metrics)
+	metrics.store.schema = newSchemaIndex(opts.Schema)
+	metrics.store.schema.exempt(healthCheckStatusName)
+
+	if opts.Recorder == nil {
+		metrics.registerCollectors(opts.Collectors)
+	}
+
+	if opts.BuildInfo != nil {
+		metrics.registerBuildInfo(opts.BuildInfo)
+	}
+
+	if opts.Schema != nil && opts.Recorder == nil {
+		metrics.registerSchema(opts.Schema)
+	}
+
+	return metrics, nil
 }
 
-// Start starts the HTTP server.  It blocks until Stop is called.
+// Start starts the HTTP server.  It blocks until the context is cancelled or
+// Stop is called and returns nil after a graceful shutdown.  Errors that
+// prevent the server from starting, such as an address that is already in use
+// or an invalid certificate, are returned.
 func (m *Metrics) Start(ctx context.Context, opts ServerOpts) error {
-	m.server = newServer(opts).WithLogger(m.logger)
-	err := m.server.Start(ctx, m.registry)
-	if !errors.Is(err, http.ErrServerClosed) {
+	server := newServer(opts).WithLogger(m.logger)
+	m.server.set(server)
+	err := server.start(ctx, m.registry, m.store, m.registerer, m.health)
+	if err != nil {
 		m.logger.Error(err, "prometheus collector endpoint error")
 	}
 
-	return nil
+	return err
+}
+
+// Ready returns a channel that is closed once the HTTP server started by Start
+// is listening.
+func (m *Metrics) Ready() <-chan struct{} {
+	return m.server.ready
 }
 
-// Stop shuts down the HTTP server gracefully.
+// Addr returns the address the HTTP server is listening on, or nil if the
+// server has not been started or is not yet listening.
+func (m *Metrics) Addr() net.Addr {
+	server := m.server.get()
+	if server == nil {
+		return nil
+	}
+	return server.Addr()
+}
+
+// DebugAddr returns the address of the separate debug listener, or nil if
+// there is none or the server is not yet listening.
+func (m *Metrics) DebugAddr() net.Addr {
+	server := m.server.get()
+	if server == nil {
+		return nil
+	}
+	return server.DebugAddr()
+}
+
+// Stop gracefully shuts down the HTTP server.  See Server.Shutdown for the
+// handling of the termination grace period.
 func (m *Metrics) Stop() {
-	// Stop has been deprecated in favor of using the callers context.
+	if server := m.server.get(); server != nil {
+		server.Stop()
+	}
+}
+
+// Shutdown gracefully shuts down the HTTP server, returning the context error
+// if the context is done before the shutdown completes.
+func (m *Metrics) Shutdown(ctx context.Context) error {
+	if server := m.server.get(); server != nil {
+		return server.Shutdown(ctx)
+	}
+	return nil
 }
 
 // WithPrefix appends additional values to the metric name to prefix any new
@@ -167,91 +272,192 @@ func (m *Metrics) WithLabels(labels ...string) *Metrics {
 	return metrics
 }
 
+// WithRegistry creates a new Metrics that shares the prefix, labels and
+// constant labels but registers its collectors with a different registry.
+// This allows metrics to be exposed on a separate endpoint, for example to
+// scrape high volume debug metrics at a different interval.  A new registry is
+// created if reg is nil.  SLOs and rates created through the new Metrics are
+// separate from the ones of the original.  Example:
+//
+//	debug := metrics.WithRegistry(prometheus.NewRegistry())
+//	_ = metrics.Start(ctx, strata.ServerOpts{
+//		Endpoints: map[string]*strata.Metrics{"/metrics/debug": debug},
+//	})
+func (m *Metrics) WithRegistry(reg *prometheus.Registry) *Metrics {
+	if reg == nil {
+		reg = prometheus.NewRegistry()
+	}
+
+	metrics := m.clone()
+	metrics.registry = reg
+	metrics.registerer = prometheus.WrapRegistererWith(prometheus.Labels(m.constantLabels), reg)
+	// The collectors in the store are registered with the original registry.
+	metrics.store = newStore()
+	metrics.store.schema = m.store.schema
+	// The trackers are keyed by name and would otherwise return the ones that
+	// were registered with the original registry.
+	metrics.slos = newSLORegistry()
+	metrics.rates = newRateRegistry()
+	return metrics
+}
+
 // CounterInc increments a counter by 1.
 func (m *Metrics) CounterInc(name string, lv ...string) {
 	defer m.recover(name, "counter_inc")
+	if m.recorder != nil {
+		m.record(CounterType, "counter_inc", name, 1, lv...)
+		return
+	}
+
 	vec, err := m.store.getCounter(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "counter_inc")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "counter_inc")
+		return
+	}
 	vec.Inc(lv...)
 }
 
 // CounterAdd increments a counter by the provided value.
 func (m *Metrics) CounterAdd(name string, v float64, lv ...string) {
 	defer m.recover(name, "counter_add")
+	if m.recorder != nil {
+		m.record(CounterType, "counter_add", name, v, lv...)
+		return
+	}
+
 	vec, err := m.store.getCounter(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "counter_add")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "counter_add")
+		return
+	}
 	vec.Add(v, lv...)
 }
 
 // GaugeSet sets a gauge to an arbitrary value.
 func (m *Metrics) GaugeSet(name string, v float64, lv ...string) {
 	defer m.recover(name, "gauge_set")
+	if m.recorder != nil {
+		m.record(GaugeType, "gauge_set", name, v, lv...)
+		return
+	}
+
 	vec, err := m.store.getGauge(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "gauge_set")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "gauge_set")
+		return
+	}
 	vec.Set(v, lv...)
 }
 
 // GaugeInc increments a gauge by 1.
 func (m *Metrics) GaugeInc(name string, lv ...string) {
 	defer m.recover(name, "gauge_inc")
+	if m.recorder != nil {
+		m.record(GaugeType, "gauge_inc", name, 1, lv...)
+		return
+	}
+
 	vec, err := m.store.getGauge(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "gauge_inc")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "gauge_inc")
+		return
+	}
 	vec.Inc(lv...)
 }
 
 // GaugeDec decrements a gauge by 1.
 func (m *Metrics) GaugeDec(name string, lv ...string) {
 	defer m.recover(name, "gauge_dec")
+	if m.recorder != nil {
+		m.record(GaugeType, "gauge_dec", name, 1, lv...)
+		return
+	}
+
 	vec, err := m.store.getGauge(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "gauge_dec")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "gauge_dec")
+		return
+	}
 	vec.Dec(lv...)
 }
 
 // GaugeAdd adds an arbitrary value to the gauge.
 func (m *Metrics) GaugeAdd(name string, v float64, lv ...string) {
 	defer m.recover(name, "gauge_add")
+	if m.recorder != nil {
+		m.record(GaugeType, "gauge_add", name, v, lv...)
+		return
+	}
+
 	vec, err := m.store.getGauge(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "gauge_add")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "gauge_add")
+		return
+	}
 	vec.Add(v, lv...)
 }
 
 // GaugeSub subtracts an arbitrary value to the gauge.
 func (m *Metrics) GaugeSub(name string, v float64, lv ...string) {
 	defer m.recover(name, "gauge_sub")
+	if m.recorder != nil {
+		m.record(GaugeType, "gauge_sub", name, v, lv...)
+		return
+	}
+
 	vec, err := m.store.getGauge(m.registerer, prefixedName(m.prefix, name, m.separator), m.labels...)
 	if err != nil {
 		m.emitError(err, name, "gauge_sub")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "gauge_sub")
+		return
+	}
 	vec.Sub(v, lv...)
 }
 
 // SummaryObserve adds a single observation to the summary.
 func (m *Metrics) SummaryObserve(name string, v float64, lv ...string) {
 	defer m.recover(name, "summary_observe")
+	if m.recorder != nil {
+		m.record(SummaryType, "summary_observe", name, v, lv...)
+		return
+	}
+
 	vec, err := m.store.getSummary(m.registerer, prefixedName(m.prefix, name, m.separator), *m.summaryOpts, m.labels...)
 	if err != nil {
 		m.emitError(err, name, "summary_timer")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "summary_timer")
+		return
+	}
 	vec.Observe(v, lv...)
 }
 
@@ -262,11 +468,18 @@ func (m *Metrics) SummaryObserve(name string, v float64, lv ...string) {
 //	defer timer.ObserveDuration()
 func (m *Metrics) SummaryTimer(name string, lv ...string) *Timer {
 	defer m.recover(name, "summary_timer")
+	if m.recorder != nil {
+		return m.recordingTimer(SummaryType, "summary_timer", name, lv...)
+	}
+
 	vec, err := m.store.getSummary(m.registerer, prefixedName(m.prefix, name, m.separator), *m.summaryOpts, m.labels...)
 	if err != nil {
 		m.emitError(err, name, "summary_timer")
-		// TODO: this is dangerous, fix me
-		return nil
+		return &Timer{}
+	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "summary_timer")
+		return &Timer{}
 	}
 	return vec.Timer(lv...)
 }
@@ -274,11 +487,20 @@ func (m *Metrics) SummaryTimer(name string, lv ...string) *Timer {
 // HistogramObserve adds a single observation to the histogram.
 func (m *Metrics) HistogramObserve(name string, v float64, lv ...string) {
 	defer m.recover(name, "histogram_observe")
+	if m.recorder != nil {
+		m.record(HistogramType, "histogram_observe", name, v, lv...)
+		return
+	}
+
 	vec, err := m.store.getHistogram(m.registerer, prefixedName(m.prefix, name, m.separator), m.histogramBuckets, m.labels...)
 	if err != nil {
 		m.emitError(err, name, "histogram_observe")
 		return
 	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "histogram_observe")
+		return
+	}
 	vec.Observe(v, lv...)
 }
 
@@ -289,20 +511,211 @@ func (m *Metrics) HistogramObserve(name string, v float64, lv ...string) {
 //	defer timer.ObserveDuration()
 func (m *Metrics) HistogramTimer(name string, lv ...string) *Timer {
 	defer m.recover(name, "histogram_timer")
+	if m.recorder != nil {
+		return m.recordingTimer(HistogramType, "histogram_timer", name, lv...)
+	}
+
 	vec, err := m.store.getHistogram(m.registerer, prefixedName(m.prefix, name, m.separator), m.histogramBuckets, m.labels...)
 	if err != nil {
 		m.emitError(err, name, "histogram_timer")
-		// TODO: this is dangerous, fix me
-		return nil
+		return &Timer{}
+	}
+	if err := m.store.admit(vec.Name(), lv); err != nil {
+		m.emitError(err, name, "histogram_timer")
+		return &Timer{}
 	}
 	return vec.Timer(lv...)
 }
 
+// GaugeFunc registers a gauge whose value is determined by calling fn at
+// scrape time.  Variable labels added with WithLabels are not applied.  The
+// callback is ignored when a Recorder is configured.  Example:
+//
+//	m.GaugeFunc("queue_length", func() float64 {
+//		return float64(len(queue))
+//	})
+func (m *Metrics) GaugeFunc(name string, fn func() float64) {
+	defer m.recover(name, "gauge_func")
+	fqName := prefixedName(m.prefix, name, m.separator)
+	if m.recorder != nil {
+		if err := m.store.schema.check(fqName, GaugeType, nil); err != nil {
+			m.emitError(err, name, "gauge_func")
+		}
+		return
+	}
+
+	err := m.store.addFunc(fqName, GaugeType, nil, func(help string) (MetricVec, error) {
+		return newGaugeFunc(m.registerer, fqName, help, fn)
+	})
+	if err != nil {
+		m.emitError(err, name, "gauge_func")
+	}
+}
+
+// CounterFunc registers a counter whose value is determined by calling fn at
+// scrape time.  The function must return monotonically increasing values.
+// Variable labels added with WithLabels are not applied.  The callback is
+// ignored when a Recorder is configured.
+func (m *Metrics) CounterFunc(name string, fn func() float64) {
+	defer m.recover(name, "counter_func")
+	fqName := prefixedName(m.prefix, name, m.separator)
+	if m.recorder != nil {
+		if err := m.store.schema.check(fqName, CounterType, nil); err != nil {
+			m.emitError(err, name, "counter_func")
+		}
+		return
+	}
+
+	err := m.store.addFunc(fqName, CounterType, nil, func(help string) (MetricVec, error) {
+		return newCounterFunc(m.registerer, fqName, help, fn)
+	})
+	if err != nil {
+		m.emitError(err, name, "counter_func")
+	}
+}
+
+// GaugeFuncVec registers a labeled gauge whose values are determined by
+// calling fn at scrape time.  Each key of the returned map holds the values of
+// the labels joined with LabelValues, and a series is exposed for every entry.
+// With a single label the key is the label value.  Example:
+//
+//	m.GaugeFuncVec("pool_connections", []string{"pool", "state"}, func() map[string]float64 {
+//		stats := pool.Stat()
+//		return map[string]float64{
+//			strata.LabelValues("primary", "idle"):  float64(stats.Idle),
+//			strata.LabelValues("primary", "inuse"): float64(stats.InUse),
+//		}
+//	})
+func (m *Metrics) GaugeFuncVec(name string, labels []string, fn func() map[string]float64) {
+	defer m.recover(name, "gauge_func_vec")
+	fqName := prefixedName(m.prefix, name, m.separator)
+	if m.recorder != nil {
+		if err := m.store.schema.check(fqName, GaugeType, labels); err != nil {
+			m.emitError(err, name, "gauge_func_vec")
+		}
+		return
+	}
+
+	err := m.store.addFunc(fqName, GaugeType, labels, func(help string) (MetricVec, error) {
+		return newGaugeFuncVec(m.registerer, fqName, help, labels, fn)
+	})
+	if err != nil {
+		m.emitError(err, name, "gauge_func_vec")
+	}
+}
+
+// CounterFuncVec registers a labeled counter whose values are determined by
+// calling fn at scrape time.  Each key of the returned map holds the values of
+// the labels joined with LabelValues, and a series is exposed for every entry.
+func (m *Metrics) CounterFuncVec(name string, labels []string, fn func() map[string]float64) {
+	defer m.recover(name, "counter_func_vec")
+	fqName := prefixedName(m.prefix, name, m.separator)
+	if m.recorder != nil {
+		if err := m.store.schema.check(fqName, CounterType, labels); err != nil {
+			m.emitError(err, name, "counter_func_vec")
+		}
+		return
+	}
+
+	err := m.store.addFunc(fqName, CounterType, labels, func(help string) (MetricVec, error) {
+		return newCounterFuncVec(m.registerer, fqName, help, labels, fn)
+	})
+	if err != nil {
+		m.emitError(err, name, "counter_func_vec")
+	}
+}
+
+// Info exposes textual information such as build or version information as
+// labels on a series with a constant value of 1.  The _info suffix is added to
+// the name if it is not already present.  Calling Info again with the same name
+// replaces the label values; the label names are fixed on first use and other
+// names are rejected with ErrInvalidLabelValues.  Variable labels added with
+// WithLabels are not applied.  Example:
+//
+//	m.Info("build", map[string]string{"version": "v1.2.3", "revision": "abc123"})
+//	// metric: build_info{revision="abc123",version="v1.2.3"} 1
+func (m *Metrics) Info(name string, labels map[string]string) {
+	defer m.recover(name, "info")
+	fqName := infoName(prefixedName(m.prefix, name, m.separator))
+	if m.recorder != nil {
+		if err := m.store.schema.check(fqName, InfoType, sortedKeys(labels)); err != nil {
+			m.emitError(err, name, "info")
+			return
+		}
+		m.recorder.record(Event{
+			Op:     "info",
+			Type:   InfoType,
+			Name:   fqName,
+			Labels: mergeLabels(m.constantLabels, labels),
+			Value:  1,
+		})
+		return
+	}
+
+	vec, err := m.store.getInfo(m.registerer, fqName, sortedKeys(labels)...)
+	if err != nil {
+		m.emitError(err, name, "info")
+		return
+	}
+
+	if err := vec.Set(labels); err != nil {
+		m.emitError(err, name, "info")
+	}
+}
+
+// StateSet returns a StateSet with one series per state.  The states are
+// changed with SetState, which sets exactly one of the states to 1.  Subsequent
+// calls with the same name return the existing StateSet.  Example:
+//
+//	breaker := m.StateSet("breaker_state", []string{"closed", "open", "half_open"})
+//	_ = breaker.SetState("open")
+func (m *Metrics) StateSet(name string, states []string) *StateSet {
+	defer m.recover(name, "stateset")
+	fqName := prefixedName(m.prefix, name, m.separator)
+	if m.recorder != nil {
+		set := newStateSet(fqName, states, m.labels...)
+		if err := m.store.schema.check(fqName, StateSetType, m.labels); err != nil {
+			m.emitError(err, name, "stateset")
+			return set
+		}
+		set.record = func(state string, lv ...string) {
+			defer m.recover(name, "stateset_set")
+			m.recorder.record(Event{
+				Op:     "stateset_set",
+				Type:   StateSetType,
+				Name:   fqName,
+				Labels: mergeLabels(m.labelMap(lv...), map[string]string{set.label: state}),
+				Value:  1,
+			})
+		}
+		return set
+	}
+
+	set, err := m.store.getStateSet(m.registerer, fqName, states, m.labels...)
+	if err != nil {
+		m.emitError(err, name, "stateset")
+		// Hand back an unregistered StateSet so callers don't need to guard
+		// against nil.
+		return newStateSet(fqName, states, m.labels...)
+	}
+
+	return set
+}
+
 func (m *Metrics) clone() *Metrics {
 	n := *m
 	return &n
 }
 
+// internal returns a Metrics without a prefix that is used for the metrics
+// managed by strata itself.  The constant labels are retained.
+func (m *Metrics) internal(labels ...string) *Metrics {
+	n := m.clone()
+	n.prefix = ""
+	n.labels = labels
+	return n
+}
+
 func (m *Metrics) emitError(err error, name string, fn string) {
 	if m.panicOnError {
 		panic(err)
@@ -315,6 +728,10 @@ func (m *Metrics) emitError(err error, name string, fn string) {
 		m.errors.RegistrationFailed(name, fn)
 	case ErrAlreadyRegistered:
 		m.errors.AlreadyRegistered(name, fn)
+	case ErrUndeclaredMetric, ErrSchemaMismatch:
+		m.errors.SchemaViolation(name, fn)
+	case ErrCardinalityLimit:
+		m.errors.CardinalityLimitExceeded(name, fn)
 	}
 }
 
@@ -340,6 +757,7 @@ func defaultedMetrics(opts MetricsOpts) MetricsOpts {
 	}
 
 	opts.SummaryOpts = defaultedSummaryOpts(opts.SummaryOpts)
+	opts.Collectors = defaultedCollectors(opts.Collectors)
 
 	return opts
 }
@@ -2,10 +2,13 @@ package strata
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var labels = map[string]string{
@@ -64,6 +67,148 @@ func TestMetricsGauge(t *testing.T) {
 	CollectAndCompare(t, vec, "strata_example_next_test_g", "gauge", nil, 0.0)
 }
 
+func TestMetricsWithRegistry(t *testing.T) {
+	m := New(MetricsOpts{
+		Registry:       prometheus.NewRegistry(),
+		ConstantLabels: []string{"service", "api"},
+		PanicOnError:   true,
+	}).WithPrefix("strata")
+
+	reg := prometheus.NewRegistry()
+	debug := m.WithRegistry(reg).WithLabels("region")
+
+	m.CounterInc("core_total")
+	debug.CounterInc("core_total", "us-east-1")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.registry, "strata_core_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "strata_core_total"))
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP strata_core_total created automagically by strata
+# TYPE strata_core_total counter
+strata_core_total{region="us-east-1",service="api"} 1
+`), "strata_core_total"))
+
+	// The new registry doesn't include the go and process collectors.
+	assert.Equal(t, 0, testutil.CollectAndCount(reg, "go_goroutines"))
+
+	// The SLOs and rates are tracked separately for each registry.
+	_, err := m.SLO("checkout", SLOOpts{})
+	require.NoError(t, err)
+	m.Rate("jobs_total", 0)
+
+	other := prometheus.NewRegistry()
+	child := m.WithRegistry(other)
+	slo, err := child.SLO("checkout", SLOOpts{})
+	require.NoError(t, err)
+	slo.Record(true, 0)
+	child.Rate("jobs_total", 0).Inc()
+
+	assert.Equal(t, 1, testutil.CollectAndCount(other, "strata_checkout_requests_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(other, "strata_jobs_rate"))
+	assert.NoError(t, testutil.GatherAndCompare(other, strings.NewReader(`
+# HELP strata_jobs_total created automagically by strata
+# TYPE strata_jobs_total counter
+strata_jobs_total{service="api"} 1
+`), "strata_jobs_total"))
+}
+
+func TestNewWithErrorLabels(t *testing.T) {
+	m, err := NewWithError(MetricsOpts{
+		Registry:       prometheus.NewRegistry(),
+		ConstantLabels: []string{"service", "api"},
+		Labels:         map[string]string{"region": "us-east-1"},
+		PanicOnError:   true,
+	})
+	require.NoError(t, err)
+
+	m.CounterInc("requests_total")
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(`
+# HELP requests_total created automagically by strata
+# TYPE requests_total counter
+requests_total{region="us-east-1",service="api"} 1
+`), "requests_total"))
+}
+
+func TestNewWithErrorInvalidLabels(t *testing.T) {
+	tests := map[string]struct {
+		opts MetricsOpts
+		err  string
+	}{
+		"odd length": {
+			opts: MetricsOpts{ConstantLabels: []string{"service", "api", "region"}},
+			err:  `"region" has no value`,
+		},
+		"duplicate pair": {
+			opts: MetricsOpts{ConstantLabels: []string{"service", "api", "service", "web"}},
+			err:  `"service" is defined more than once`,
+		},
+		"duplicate map": {
+			opts: MetricsOpts{
+				ConstantLabels: []string{"service", "api"},
+				Labels:         map[string]string{"service": "web"},
+			},
+			err: `"service" is defined more than once`,
+		},
+		"invalid name": {
+			opts: MetricsOpts{Labels: map[string]string{"my-service": "api"}},
+			err:  `invalid label name "my-service"`,
+		},
+		"reserved name": {
+			opts: MetricsOpts{Labels: map[string]string{"__name__": "api"}},
+			err:  `invalid label name "__name__"`,
+		},
+		"invalid value": {
+			opts: MetricsOpts{Labels: map[string]string{"service": "\xff"}},
+			err:  "not valid UTF-8",
+		},
+		"schema collision": {
+			opts: MetricsOpts{
+				Labels: map[string]string{"method": "GET"},
+				Schema: &Schema{Metrics: []MetricSchema{
+					{Name: "requests_total", Type: CounterType, Labels: []string{"method"}},
+				}},
+			},
+			err: `label "method" of requests_total collides with a constant label`,
+		},
+		"build info collision": {
+			opts: MetricsOpts{
+				Labels:    map[string]string{"env": "prod"},
+				BuildInfo: &BuildInfoOpts{Labels: map[string]string{"env": "prod"}},
+			},
+			err: `build info label "env" collides with a constant label`,
+		},
+		"built-in build info collision": {
+			opts: MetricsOpts{
+				Labels:    map[string]string{"version": "x"},
+				BuildInfo: &BuildInfoOpts{},
+			},
+			err: `build info label "version" collides with a constant label`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := NewWithError(tt.opts)
+			assert.Nil(t, m)
+			assert.ErrorIs(t, err, ErrInvalidConstantLabels)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewPanicsOnInvalidLabels(t *testing.T) {
+	assert.PanicsWithError(t, `invalid constant labels: ConstantLabels must contain label/value pairs, "service" has no value`, func() {
+		New(MetricsOpts{ConstantLabels: []string{"service"}})
+	})
+}
+
+func TestSlicePairsToMap(t *testing.T) {
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, SlicePairsToMap([]string{"a", "1", "b", "2"}))
+	assert.Equal(t, map[string]string{"a": "1"}, SlicePairsToMap([]string{"a", "1", "b"}))
+	assert.Equal(t, map[string]string{"a": "2"}, SlicePairsToMap([]string{"a", "1", "a", "2"}))
+	assert.Empty(t, SlicePairsToMap(nil))
+}
+
 func getCounter(metrics *Metrics, n string) (MetricVec, error) {
 	if v, ok := metrics.store.counters[n]; ok {
 		return v, nil
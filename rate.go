@@ -0,0 +1,285 @@
+package strata
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateWindow is the window used when the window of a rate is not
+	// positive.
+	DefaultRateWindow = time.Minute
+	// DefaultEWMAAlpha is the smoothing factor used when the alpha of a moving
+	// average is not between 0 and 1.  It approximates a one minute moving
+	// average.
+	DefaultEWMAAlpha = 0.08
+
+	// rateSlots is the number of intervals the window of a rate is divided
+	// into.  Increments expire from the window one interval at a time.
+	rateSlots = 100
+	// ewmaInterval is the interval the moving average is updated with the
+	// rate of the increments.
+	ewmaInterval = 5 * time.Second
+)
+
+// Rate is a counter that also computes its per-second rate over a sliding
+// window in process.  It is created with Metrics.Rate and maintains the
+// following metrics:
+//
+//	<name>         counter of the increments
+//	<base>_rate    per-second rate over the window, where base is the name
+//	               without the _total suffix
+type Rate struct {
+	metrics  *Metrics
+	name     string
+	window   time.Duration
+	interval time.Duration
+	start    time.Time
+	slots    [rateSlots]rateSlot
+	now      func() time.Time
+	sync.Mutex
+}
+
+type rateSlot struct {
+	index int64
+	value float64
+}
+
+// Rate returns the rate of the counter with the name, computed over the
+// sliding window.  The counter is registered through the store using the
+// prefix of the metrics and without variable labels.  Calling Rate again with
+// the same name returns the existing rate and ignores the window.  A name that
+// is tracked by EWMA is rejected with ErrAlreadyRegistered because both would
+// increment the counter, and the returned rate doesn't update it.  Example:
+//
+//	requests := m.Rate("requests_total", time.Minute)
+//	requests.Inc()
+//	perSecond := requests.Value()
+func (m *Metrics) Rate(name string, window time.Duration) *Rate {
+	if window <= 0 {
+		window = DefaultRateWindow
+	}
+	fqName := prefixedName(m.prefix, name, m.separator)
+
+	m.rates.Lock()
+	defer m.rates.Unlock()
+
+	if r, ok := m.rates.rates[fqName]; ok {
+		return r
+	}
+
+	interval := window / rateSlots
+	if interval <= 0 {
+		interval = 1
+	}
+
+	r := &Rate{
+		name:     name,
+		window:   window,
+		interval: interval,
+		start:    time.Now(),
+		now:      time.Now,
+	}
+
+	if _, ok := m.rates.ewmas[fqName]; ok {
+		m.emitError(ErrAlreadyRegistered, name, "rate")
+		return r
+	}
+
+	r.metrics = m.WithLabels()
+	m.rates.rates[fqName] = r
+
+	if m.recorder == nil {
+		r.metrics.CounterAdd(name, 0)
+		r.metrics.GaugeFunc(companionName(name, "rate"), r.Value)
+	}
+
+	return r
+}
+
+// Inc increments the counter by 1.
+func (r *Rate) Inc() {
+	r.Add(1)
+}
+
+// Add increases the counter by the given value.
+func (r *Rate) Add(v float64) {
+	if r.metrics != nil {
+		r.metrics.CounterAdd(r.name, v)
+	}
+
+	index := r.now().UnixNano() / int64(r.interval)
+
+	r.Lock()
+	defer r.Unlock()
+
+	slot := &r.slots[index%rateSlots]
+	if slot.index != index {
+		*slot = rateSlot{index: index}
+	}
+	slot.value += v
+}
+
+// Value returns the per-second rate of the increments in the window.  Until
+// the window has elapsed since the rate was created, the rate is computed
+// over the elapsed time.
+func (r *Rate) Value() float64 {
+	now := r.now()
+	index := now.UnixNano() / int64(r.interval)
+
+	r.Lock()
+	defer r.Unlock()
+
+	var sum float64
+	for _, slot := range r.slots {
+		if slot.index > index-rateSlots && slot.index <= index {
+			sum += slot.value
+		}
+	}
+
+	elapsed := min(max(now.Sub(r.start), r.interval), r.window)
+	return sum / elapsed.Seconds()
+}
+
+// EWMA is a counter that also computes the exponentially weighted moving
+// average of its per-second rate in process.  It is created with Metrics.EWMA
+// and maintains the following metrics:
+//
+//	<name>         counter of the increments
+//	<base>_ewma    moving average of the per-second rate, where base is the
+//	               name without the _total suffix
+//
+// The average is updated every five seconds with the rate of the increments
+// in the interval.
+type EWMA struct {
+	metrics *Metrics
+	name    string
+	alpha   float64
+	value   float64
+	pending float64
+	tick    time.Time
+	started bool
+	now     func() time.Time
+	sync.Mutex
+}
+
+// EWMA returns the moving average of the rate of the counter with the name.
+// Alpha is the weight of the most recent interval.  The counter is registered
+// through the store using the prefix of the metrics and without variable
+// labels.  Calling EWMA again with the same name returns the existing average
+// and ignores alpha.  A name that is tracked by Rate is rejected with
+// ErrAlreadyRegistered, and the returned average doesn't update the counter.
+// Example:
+//
+//	requests := m.EWMA("requests_total", strata.DefaultEWMAAlpha)
+//	requests.Inc()
+//	perSecond := requests.Value()
+func (m *Metrics) EWMA(name string, alpha float64) *EWMA {
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultEWMAAlpha
+	}
+	fqName := prefixedName(m.prefix, name, m.separator)
+
+	m.rates.Lock()
+	defer m.rates.Unlock()
+
+	if e, ok := m.rates.ewmas[fqName]; ok {
+		return e
+	}
+
+	e := &EWMA{
+		name:  name,
+		alpha: alpha,
+		tick:  time.Now(),
+		now:   time.Now,
+	}
+
+	if _, ok := m.rates.rates[fqName]; ok {
+		m.emitError(ErrAlreadyRegistered, name, "ewma")
+		return e
+	}
+
+	e.metrics = m.WithLabels()
+	m.rates.ewmas[fqName] = e
+
+	if m.recorder == nil {
+		e.metrics.CounterAdd(name, 0)
+		e.metrics.GaugeFunc(companionName(name, "ewma"), e.Value)
+	}
+
+	return e
+}
+
+// Inc increments the counter by 1.
+func (e *EWMA) Inc() {
+	e.Add(1)
+}
+
+// Add increases the counter by the given value.
+func (e *EWMA) Add(v float64) {
+	if e.metrics != nil {
+		e.metrics.CounterAdd(e.name, v)
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	e.update(e.now())
+	e.pending += v
+}
+
+// Value returns the moving average of the per-second rate.  It is 0 until the
+// first interval has elapsed.
+func (e *EWMA) Value() float64 {
+	e.Lock()
+	defer e.Unlock()
+
+	e.update(e.now())
+	return e.value
+}
+
+// update applies the intervals that have elapsed since the last update.  The
+// pending increments belong to the first interval, and the intervals after it
+// have no increments.
+func (e *EWMA) update(now time.Time) {
+	ticks := int64(now.Sub(e.tick) / ewmaInterval)
+	if ticks <= 0 {
+		return
+	}
+
+	rate := e.pending / ewmaInterval.Seconds()
+	if e.started {
+		e.value += e.alpha * (rate - e.value)
+	} else {
+		e.value = rate
+		e.started = true
+	}
+	e.value *= math.Pow(1-e.alpha, float64(ticks-1))
+
+	e.pending = 0
+	e.tick = e.tick.Add(time.Duration(ticks) * ewmaInterval)
+}
+
+// rateRegistry holds the rates and moving averages created by the metrics,
+// keyed by the full name.  It is shared by the metrics derived with WithPrefix
+// and WithLabels.
+type rateRegistry struct {
+	rates map[string]*Rate
+	ewmas map[string]*EWMA
+	sync.Mutex
+}
+
+func newRateRegistry() *rateRegistry {
+	return &rateRegistry{
+		rates: make(map[string]*Rate),
+		ewmas: make(map[string]*EWMA),
+	}
+}
+
+// companionName returns the name of the gauge that accompanies the counter
+// with the name.
+func companionName(name string, suffix string) string {
+	return strings.TrimSuffix(name, "_total") + "_" + suffix
+}
@@ -0,0 +1,126 @@
+package strata
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRate(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true}).WithPrefix("app")
+
+	r := m.Rate("requests_total", time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.start = now
+	r.now = func() time.Time { return now }
+
+	assert.Equal(t, 0.0, r.Value())
+
+	now = now.Add(10 * time.Second)
+	r.Add(10)
+	// The rate is computed over the elapsed time until the window is full.
+	assert.InDelta(t, 1.0, r.Value(), 1e-9)
+
+	now = now.Add(50 * time.Second)
+	r.Add(20)
+	r.Inc()
+	r.Inc()
+	assert.InDelta(t, 32.0/60, r.Value(), 1e-9)
+
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(`
+# HELP app_requests_total created automagically by strata
+# TYPE app_requests_total counter
+app_requests_total 32
+# HELP app_requests_rate created automagically by strata
+# TYPE app_requests_rate gauge
+app_requests_rate 0.5333333333333333
+`), "app_requests_total", "app_requests_rate"))
+
+	// The first increments expire from the window.
+	now = now.Add(30 * time.Second)
+	assert.InDelta(t, 22.0/60, r.Value(), 1e-9)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 0.0, r.Value())
+
+	assert.Same(t, r, m.WithLabels("ignored").Rate("requests_total", time.Hour))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.registry, "app_requests_total"))
+}
+
+func TestEWMA(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true})
+
+	e := m.EWMA("jobs_total", 0.5)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.tick = now
+	e.now = func() time.Time { return now }
+
+	e.Add(50)
+	assert.Equal(t, 0.0, e.Value())
+
+	// The first interval initializes the average.
+	now = now.Add(ewmaInterval)
+	assert.InDelta(t, 10.0, e.Value(), 1e-9)
+
+	e.Add(100)
+	now = now.Add(ewmaInterval + time.Second)
+	assert.InDelta(t, 15.0, e.Value(), 1e-9)
+
+	// Intervals without increments decay the average.
+	now = now.Add(2 * ewmaInterval)
+	assert.InDelta(t, 3.75, e.Value(), 1e-9)
+
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(`
+# HELP jobs_total created automagically by strata
+# TYPE jobs_total counter
+jobs_total 150
+# HELP jobs_ewma created automagically by strata
+# TYPE jobs_ewma gauge
+jobs_ewma 3.75
+`), "jobs_total", "jobs_ewma"))
+
+	assert.Same(t, e, m.EWMA("jobs_total", 0.1))
+	assert.Equal(t, DefaultEWMAAlpha, m.EWMA("other_total", 2).alpha)
+}
+
+func TestRateEWMASameName(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry()})
+
+	r := m.Rate("requests_total", 0)
+	e := m.EWMA("requests_total", 0)
+	r.Inc()
+	e.Inc()
+
+	// The counter is only incremented by the rate.
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(`
+# HELP requests_total created automagically by strata
+# TYPE requests_total counter
+requests_total 1
+`), "requests_total"))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.registry, "requests_ewma"))
+
+	m = New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true})
+	m.EWMA("jobs_total", 0)
+	assert.PanicsWithValue(t, ErrAlreadyRegistered, func() {
+		m.Rate("jobs_total", 0)
+	})
+}
+
+func TestRateRecorder(t *testing.T) {
+	rec := NewRecorder()
+	m := New(MetricsOpts{Recorder: rec})
+
+	r := m.Rate("requests_total", 0)
+	assert.Equal(t, DefaultRateWindow, r.window)
+	r.Add(3)
+	m.EWMA("jobs_total", 0).Inc()
+
+	require.Len(t, rec.Events(), 2)
+	assert.Equal(t, "requests_total", rec.Events()[0].Name)
+	assert.Equal(t, "jobs_total", rec.Events()[1].Name)
+}
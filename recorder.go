@@ -0,0 +1,198 @@
+package strata
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Event is a single metric operation captured by the Recorder.
+type Event struct {
+	// Op is the operation that generated the event, e.g. counter_inc or
+	// histogram_observe.
+	Op string
+	// Type is the type of the metric the operation was performed on.
+	Type MetricType
+	// Name is the fully prefixed metric name.
+	Name string
+	// Labels contains the constant and variable labels of the series.
+	Labels map[string]string
+	// Value is the value passed to the operation.  Operations without an
+	// explicit value such as counter_inc record 1.  Timers record the observed
+	// duration in seconds.
+	Value float64
+}
+
+// Recorder is an in-memory metrics backend that captures every operation as
+// an Event instead of updating prometheus collectors.  It is intended for unit
+// tests and dry runs where a registry isn't wanted.  Example:
+//
+//	rec := strata.NewRecorder()
+//	m := strata.New(strata.MetricsOpts{Recorder: rec})
+//	m.CounterInc("requests_total")
+//	rec.Value("requests_total", nil) // 1
+type Recorder struct {
+	events []Event
+	sync.Mutex
+}
+
+// NewRecorder creates a new empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		events: make([]Event, 0),
+	}
+}
+
+// Events returns a snapshot of all of the recorded events in the order that
+// they were recorded.
+func (r *Recorder) Events() []Event {
+	r.Lock()
+	defer r.Unlock()
+
+	events := make([]Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// Find returns all of the events recorded for the metric name in the order
+// that they were recorded.
+func (r *Recorder) Find(name string) []Event {
+	r.Lock()
+	defer r.Unlock()
+
+	events := make([]Event, 0)
+	for _, e := range r.events {
+		if e.Name == name {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Names returns the metric names of all of the recorded events in the order
+// that they were recorded.  It is useful for checking call ordering.
+func (r *Recorder) Names() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	names := make([]string, len(r.events))
+	for i, e := range r.events {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// Value folds the counter and gauge events recorded for the metric name into
+// the value that the series would currently hold.  Only events whose labels
+// contain all of the provided labels are included.  Observations from
+// histograms, summaries and timers are not folded, use Find to inspect them.
+func (r *Recorder) Value(name string, labels map[string]string) float64 {
+	r.Lock()
+	defer r.Unlock()
+
+	var v float64
+	for _, e := range r.events {
+		if e.Name != name || !matchLabels(e.Labels, labels) {
+			continue
+		}
+
+		switch e.Op {
+		case "counter_inc", "counter_add", "gauge_inc", "gauge_add":
+			v += e.Value
+		case "gauge_dec", "gauge_sub":
+			v -= e.Value
+		case "gauge_set":
+			v = e.Value
+		}
+	}
+	return v
+}
+
+// Len returns the number of recorded events.
+func (r *Recorder) Len() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.events)
+}
+
+// Reset discards all of the recorded events.  It is commonly used between
+// subtests.
+func (r *Recorder) Reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.events = make([]Event, 0)
+}
+
+func (r *Recorder) record(e Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+}
+
+func matchLabels(have map[string]string, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// recordingTimer returns a Timer that records the observed duration as an
+// event rather than observing a prometheus collector.
+func (m *Metrics) recordingTimer(mtype MetricType, op string, name string, lv ...string) *Timer {
+	if err := m.store.schema.check(prefixedName(m.prefix, name, m.separator), mtype, m.labels); err != nil {
+		m.emitError(err, name, op)
+		return &Timer{}
+	}
+
+	labels := m.labelMap(lv...)
+	return &Timer{
+		timer: prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+			m.recorder.record(Event{
+				Op:     op,
+				Type:   mtype,
+				Name:   prefixedName(m.prefix, name, m.separator),
+				Labels: labels,
+				Value:  v,
+			})
+		})),
+	}
+}
+
+// record records the event.  The schema is checked like the store does for
+// the collectors, so a Recorder reports the same violations.
+func (m *Metrics) record(mtype MetricType, op string, name string, v float64, lv ...string) {
+	fqName := prefixedName(m.prefix, name, m.separator)
+	if err := m.store.schema.check(fqName, mtype, m.labels); err != nil {
+		m.emitError(err, name, op)
+		return
+	}
+
+	m.recorder.record(Event{
+		Op:     op,
+		Type:   mtype,
+		Name:   fqName,
+		Labels: m.labelMap(lv...),
+		Value:  v,
+	})
+}
+
+// labelMap merges the constant labels with the variable labels and their
+// values.  Like the prometheus collectors it panics if the number of values
+// doesn't match the number of labels, which is recovered by the caller.
+func (m *Metrics) labelMap(lv ...string) map[string]string {
+	if len(lv) != len(m.labels) {
+		panic(fmt.Errorf("%w: expected %d label values but got %d", ErrInvalidLabelValues, len(m.labels), len(lv)))
+	}
+
+	labels := make(map[string]string, len(m.constantLabels)+len(m.labels))
+	for k, v := range m.constantLabels {
+		labels[k] = v
+	}
+	for i, l := range m.labels {
+		labels[l] = lv[i]
+	}
+	return labels
+}
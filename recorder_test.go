@@ -0,0 +1,87 @@
+package strata
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder()
+	reg := prometheus.NewPedanticRegistry()
+	m := New(MetricsOpts{
+		Registry:       reg,
+		Recorder:       rec,
+		PanicOnError:   true,
+		ConstantLabels: []string{"role", "server"},
+	}).WithPrefix("strata", "example").WithLabels("region")
+
+	m.CounterInc("test_total", "us-east-1")
+	m.CounterAdd("test_total", 5.0, "us-east-1")
+	m.GaugeSet("test_g", 10.0, "us-east-1")
+	m.GaugeDec("test_g", "us-east-1")
+	m.GaugeSub("test_g", 4.0, "us-east-1")
+	m.HistogramObserve("test_hst", 0.5, "us-east-1")
+	m.SummaryObserve("test_smy", 0.25, "us-east-1")
+	m.HistogramTimer("test_timer", "us-east-1").ObserveDuration()
+
+	assert.Equal(t, 8, rec.Len())
+	assert.Equal(t, []string{
+		"strata_example_test_total",
+		"strata_example_test_total",
+		"strata_example_test_g",
+		"strata_example_test_g",
+		"strata_example_test_g",
+		"strata_example_test_hst",
+		"strata_example_test_smy",
+		"strata_example_test_timer",
+	}, rec.Names())
+
+	assert.Equal(t, 6.0, rec.Value("strata_example_test_total", map[string]string{"region": "us-east-1"}))
+	assert.Equal(t, 0.0, rec.Value("strata_example_test_total", map[string]string{"region": "us-west-2"}))
+	assert.Equal(t, 5.0, rec.Value("strata_example_test_g", nil))
+
+	events := rec.Find("strata_example_test_hst")
+	assert.Len(t, events, 1)
+	assert.Equal(t, Event{
+		Op:     "histogram_observe",
+		Type:   HistogramType,
+		Name:   "strata_example_test_hst",
+		Labels: map[string]string{"role": "server", "region": "us-east-1"},
+		Value:  0.5,
+	}, events[0])
+
+	timer := rec.Find("strata_example_test_timer")
+	assert.Len(t, timer, 1)
+	assert.Equal(t, "histogram_timer", timer[0].Op)
+
+	// Nothing should have been registered with the registry.
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Empty(t, mfs)
+
+	rec.Reset()
+	assert.Equal(t, 0, rec.Len())
+	assert.Empty(t, rec.Events())
+}
+
+func TestRecorderInvalidLabelValues(t *testing.T) {
+	rec := NewRecorder()
+	m := New(MetricsOpts{
+		Recorder:     rec,
+		PanicOnError: true,
+	}).WithLabels("region")
+
+	assert.Panics(t, func() {
+		m.CounterInc("test_total")
+	})
+
+	n := New(MetricsOpts{
+		Recorder: rec,
+	}).WithLabels("region")
+	assert.NotPanics(t, func() {
+		n.CounterInc("test_total", "us-east-1", "extra")
+	})
+	assert.Equal(t, 0, rec.Len())
+}
@@ -0,0 +1,140 @@
+package strata
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// certReloader loads a certificate and key pair and serves it through
+// tls.Config.GetCertificate.  The pair can be reloaded while the server is
+// running, which allows certificates rotated by tools such as cert-manager to
+// be picked up without a restart.  If a new pair fails to load, the current
+// certificate continues to be served.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   Logger
+	cert     *tls.Certificate
+	certStat fileStat
+	keyStat  fileStat
+	sync.RWMutex
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(certFile, keyFile string, logger Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate.  It is used as the
+// tls.Config GetCertificate callback.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// reload loads the certificate and key pair.  Failures are logged and the
+// current certificate is kept.
+func (r *certReloader) reload() {
+	if err := r.load(); err != nil {
+		r.logger.Error(err, "unable to reload tls certificate, keeping the current certificate",
+			"certFile", r.certFile, "keyFile", r.keyFile)
+		return
+	}
+
+	r.logger.Info("reloaded tls certificate", "certFile", r.certFile, "keyFile", r.keyFile)
+}
+
+func (r *certReloader) load() error {
+	// Stat before reading so a change that lands while loading is picked up
+	// by the next check.
+	certStat, keyStat := stat(r.certFile), stat(r.keyFile)
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+
+	r.Lock()
+	defer r.Unlock()
+	// The stats are updated even on failure so a broken pair is only retried
+	// once the files change again.
+	r.certStat = certStat
+	r.keyStat = keyStat
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	return nil
+}
+
+// changed returns true if either the certificate or key file has been
+// modified since the pair was last loaded.
+func (r *certReloader) changed() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return stat(r.certFile) != r.certStat || stat(r.keyFile) != r.keyStat
+}
+
+// watch reloads the pair when the files change, checking every interval, and
+// when a SIGHUP is received if enabled.  It returns when the context is done.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration, sighup bool) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var hup chan os.Signal
+	if sighup {
+		hup = make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+	}
+
+	if tick == nil && hup == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			if r.changed() {
+				r.reload()
+			}
+		case <-hup:
+			r.reload()
+		}
+	}
+}
+
+func stat(path string) fileStat {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}
+	}
+	return fileStat{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
@@ -0,0 +1,65 @@
+package strata
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func currentCert(t *testing.T, r *certReloader) []byte {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	return cert.Certificate[0]
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certs := generateTestCerts(t, dir)
+
+	r, err := newCertReloader(certs.certFile, certs.keyFile, logr.New(nil))
+	require.NoError(t, err)
+	original := currentCert(t, r)
+	assert.False(t, r.changed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx, 10*time.Millisecond, false)
+
+	// Rotate the pair in place.
+	rotated := generateTestCerts(t, t.TempDir())
+	for src, dst := range map[string]string{rotated.certFile: certs.certFile, rotated.keyFile: certs.keyFile} {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, data, 0o600))
+	}
+
+	require.Eventually(t, func() bool {
+		return !bytes.Equal(original, currentCert(t, r))
+	}, 5*time.Second, 10*time.Millisecond)
+	current := currentCert(t, r)
+
+	// A broken pair keeps the current certificate.
+	require.NoError(t, os.WriteFile(certs.certFile, []byte("not a certificate"), 0o600))
+	require.Eventually(t, func() bool {
+		return !r.changed()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, current, currentCert(t, r))
+
+	r.reload()
+	assert.Equal(t, current, currentCert(t, r))
+}
+
+func TestCertReloaderInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certs := generateTestCerts(t, dir)
+
+	_, err := newCertReloader(certs.keyFile, certs.certFile, logr.New(nil))
+	assert.Error(t, err)
+}
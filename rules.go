@@ -0,0 +1,338 @@
+package strata
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultRuleName is the name of the generated PrometheusRule.
+	DefaultRuleName = "strata"
+
+	sliLabel = "sli"
+)
+
+// burnRate is a multi-window, multi-burn-rate alert condition.  The alert
+// fires when the error ratio over both windows exceeds the burn rate times
+// the error budget.
+type burnRate struct {
+	long     string
+	short    string
+	factor   string
+	severity string
+}
+
+// burnRates are the conditions recommended by the Google SRE workbook for a
+// 30 day SLO window.  The page conditions consume 2% of the budget in an hour
+// and 5% in six hours, the ticket conditions 10% in a day and 10% in three
+// days.
+var burnRates = []burnRate{ //nolint:gochecknoglobals
+	{long: "1h", short: "5m", factor: "14.4", severity: "page"},
+	{long: "6h", short: "30m", factor: "6", severity: "page"},
+	{long: "1d", short: "2h", factor: "3", severity: "ticket"},
+	{long: "3d", short: "6h", factor: "1", severity: "ticket"},
+}
+
+// sliWindows are the windows of the recorded error ratios used by the alerts.
+var sliWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"} //nolint:gochecknoglobals
+
+// SLI declares the metrics of a service level indicator.  The requests and
+// errors are counters, and the latency is a histogram.  Example:
+//
+//	err := metrics.DeclareSLI(strata.SLI{
+//		Name:          "api",
+//		Requests:      "http_requests_total",
+//		ErrorMatchers: []string{`code=~"5.."`},
+//		Latency:       "http_request_duration_seconds",
+//		Objective:     0.999,
+//	})
+type SLI struct {
+	// Name identifies the SLI.  It is added as the sli label to the recorded
+	// series and the alerts.
+	Name string
+	// Requests is the name of the counter of all requests.  The prefix of the
+	// metrics is added to the name.  Selectors are not supported because the
+	// error matchers are added to the name.
+	Requests string
+	// Errors is the name of the counter of the failed requests.  The prefix
+	// of the metrics is added to the name.
+	Errors string
+	// ErrorMatchers are label matchers that select the failed requests from
+	// the Requests counter, e.g. code=~"5..".  They can be used instead of
+	// Errors.
+	ErrorMatchers []string
+	// Latency is the name of the histogram of the request durations.  The
+	// prefix of the metrics is added to the name.  The latency quantiles are
+	// not recorded unless it is set.
+	Latency string
+	// Quantiles are the latency quantiles that are recorded.  By default
+	// DefaultDashboardQuantiles are used.
+	Quantiles []float64
+	// Objective is the target ratio of successful requests, e.g. 0.999.
+	Objective float64
+	// Labels are the labels the rates and ratios are aggregated by.  By
+	// default the SLI is aggregated across all series.
+	Labels []string
+}
+
+func (s *SLI) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("%w: the name is required", ErrInvalidSLI)
+	}
+
+	if s.Requests == "" {
+		return fmt.Errorf("%w: %s: the requests counter is required", ErrInvalidSLI, s.Name)
+	}
+
+	if (s.Errors == "") == (len(s.ErrorMatchers) == 0) {
+		return fmt.Errorf("%w: %s: either the errors counter or the error matchers are required", ErrInvalidSLI, s.Name)
+	}
+
+	for _, name := range []string{s.Requests, s.Errors, s.Latency} {
+		if strings.ContainsAny(name, "{}") {
+			return fmt.Errorf("%w: %s: %q must be a metric name without a selector", ErrInvalidSLI, s.Name, name)
+		}
+		if name != "" && !metricNameRegexp.MatchString(name) {
+			return fmt.Errorf("%w: %s: invalid metric name %q", ErrInvalidSLI, s.Name, name)
+		}
+	}
+
+	for _, matcher := range s.ErrorMatchers {
+		if _, err := parseMatcher(matcher); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidSLI, s.Name, err)
+		}
+	}
+
+	if s.Objective <= 0 || s.Objective >= 1 {
+		return fmt.Errorf("%w: %s: the objective %v is not between 0 and 1", ErrInvalidSLI, s.Name, s.Objective)
+	}
+
+	for _, q := range s.Quantiles {
+		if q <= 0 || q >= 1 {
+			return fmt.Errorf("%w: %s: the quantile %v is not between 0 and 1", ErrInvalidSLI, s.Name, q)
+		}
+	}
+
+	for _, l := range s.Labels {
+		if err := validateLabelName(l); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidSLI, s.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// sliRegistry holds the SLIs declared by the metrics.  It is shared by the
+// metrics derived with WithPrefix and WithLabels.
+type sliRegistry struct {
+	slis []SLI
+	sync.Mutex
+}
+
+// DeclareSLI declares a service level indicator that is included in the rules
+// generated by PrometheusRules.  The prefix of the metrics is added to the
+// metric names.  An error wrapping ErrInvalidSLI is returned if the SLI is
+// invalid or an SLI with the same name has already been declared.
+func (m *Metrics) DeclareSLI(sli SLI) error {
+	if err := sli.validate(); err != nil {
+		return err
+	}
+
+	sli.Requests = prefixedName(m.prefix, sli.Requests, m.separator)
+	if sli.Errors != "" {
+		sli.Errors = prefixedName(m.prefix, sli.Errors, m.separator)
+	}
+	if sli.Latency != "" {
+		sli.Latency = prefixedName(m.prefix, sli.Latency, m.separator)
+	}
+	if len(sli.Quantiles) == 0 {
+		sli.Quantiles = DefaultDashboardQuantiles
+	}
+
+	m.slis.Lock()
+	defer m.slis.Unlock()
+
+	for _, s := range m.slis.slis {
+		if s.Name == sli.Name {
+			return fmt.Errorf("%w: %s is declared more than once", ErrInvalidSLI, sli.Name)
+		}
+	}
+	m.slis.slis = append(m.slis.slis, sli)
+
+	return nil
+}
+
+// RuleOpts defines the metadata of the generated PrometheusRule.
+type RuleOpts struct {
+	// Name is the name of the PrometheusRule.  By default DefaultRuleName is
+	// used.
+	Name string
+	// Namespace is the namespace of the PrometheusRule.
+	Namespace string
+	// Labels are the labels of the PrometheusRule, which are commonly used by
+	// the Prometheus Operator to select the rules.
+	Labels map[string]string
+}
+
+// PrometheusRules generates a PrometheusRule manifest for the declared SLIs.
+// For each SLI a recording group records the request and error rates, the
+// error ratios and the latency quantiles, and an alerting group contains the
+// multi-window, multi-burn-rate alerts for the objective.  The spec can also
+// be used as a Prometheus rule file.
+func (m *Metrics) PrometheusRules(opts RuleOpts) ([]byte, error) {
+	if opts.Name == "" {
+		opts.Name = DefaultRuleName
+	}
+
+	m.slis.Lock()
+	slis := append([]SLI{}, m.slis.slis...)
+	m.slis.Unlock()
+
+	rule := prometheusRule{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       "PrometheusRule",
+		Metadata: ruleMetadata{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+			Labels:    opts.Labels,
+		},
+		Spec: ruleSpec{Groups: make([]ruleGroup, 0, len(slis)*2)},
+	}
+
+	for _, sli := range slis {
+		rule.Spec.Groups = append(rule.Spec.Groups, sliRecordingGroup(sli), sliAlertGroup(sli))
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(rule); err != nil {
+		return nil, fmt.Errorf("unable to encode rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("unable to encode rules: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func sliRecordingGroup(sli SLI) ruleGroup {
+	by := strings.Join(sli.Labels, ", ")
+	labels := map[string]string{sliLabel: sli.Name}
+	failed := sli.Errors
+	if failed == "" {
+		failed = sli.Requests + "{" + strings.Join(sli.ErrorMatchers, ", ") + "}"
+	}
+
+	rules := []rule{
+		{
+			Record: "sli:requests:rate5m",
+			Expr:   aggregate("sum", by, fmt.Sprintf("rate(%s[5m])", sli.Requests)),
+			Labels: labels,
+		},
+		{
+			Record: "sli:errors:rate5m",
+			Expr:   aggregate("sum", by, fmt.Sprintf("rate(%s[5m])", failed)),
+			Labels: labels,
+		},
+	}
+
+	for _, w := range sliWindows {
+		rules = append(rules, rule{
+			Record: "sli:error_ratio:rate" + w,
+			Expr: aggregate("sum", by, fmt.Sprintf("rate(%s[%s])", failed, w)) + "\n/\n" +
+				aggregate("sum", by, fmt.Sprintf("rate(%s[%s])", sli.Requests, w)),
+			Labels: labels,
+		})
+	}
+
+	if sli.Latency != "" {
+		for _, q := range sli.Quantiles {
+			rules = append(rules, rule{
+				Record: "sli:latency:quantile5m",
+				Expr: fmt.Sprintf("histogram_quantile(%s, %s)", formatQuantile(q),
+					aggregate("sum", strings.Join(append([]string{"le"}, sli.Labels...), ", "),
+						fmt.Sprintf("rate(%s_bucket[5m])", sli.Latency))),
+				Labels: map[string]string{sliLabel: sli.Name, "quantile": formatQuantile(q)},
+			})
+		}
+	}
+
+	return ruleGroup{Name: sli.Name + "-sli-recording", Rules: rules}
+}
+
+func sliAlertGroup(sli SLI) ruleGroup {
+	budget := "(1 - " + strconv.FormatFloat(sli.Objective, 'f', -1, 64) + ")"
+	selector := fmt.Sprintf("{%s=%q}", sliLabel, sli.Name)
+
+	conditions := make(map[string][]string)
+	for _, b := range burnRates {
+		conditions[b.severity] = append(conditions[b.severity], fmt.Sprintf(
+			"(\n  sli:error_ratio:rate%s%s > (%s * %s)\nand\n  sli:error_ratio:rate%s%s > (%s * %s)\n)",
+			b.long, selector, b.factor, budget, b.short, selector, b.factor, budget,
+		))
+	}
+
+	rules := make([]rule, 0, 2)
+	for _, severity := range []string{"page", "ticket"} {
+		wait := "2m"
+		if severity == "ticket" {
+			wait = "15m"
+		}
+
+		rules = append(rules, rule{
+			Alert: "SLOErrorBudgetBurn",
+			Expr:  strings.Join(conditions[severity], "\nor\n"),
+			For:   wait,
+			Labels: map[string]string{
+				sliLabel:   sli.Name,
+				"severity": severity,
+			},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("%s is burning its error budget too fast", sli.Name),
+				"description": fmt.Sprintf(
+					"The error ratio of %s is high enough to exhaust the error budget of the %s objective.",
+					sli.Name, strconv.FormatFloat(sli.Objective*100, 'g', 6, 64)+"%",
+				),
+			},
+		})
+	}
+
+	return ruleGroup{Name: sli.Name + "-slo-alerts", Rules: rules}
+}
+
+type prometheusRule struct {
+	APIVersion string       `yaml:"apiVersion"`
+	Kind       string       `yaml:"kind"`
+	Metadata   ruleMetadata `yaml:"metadata"`
+	Spec       ruleSpec     `yaml:"spec"`
+}
+
+type ruleMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type ruleSpec struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
@@ -0,0 +1,218 @@
+package strata
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPrometheusRules(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true}).WithPrefix("api")
+	require.NoError(t, m.DeclareSLI(SLI{
+		Name:          "api",
+		Requests:      "http_requests_total",
+		ErrorMatchers: []string{`code=~"5.."`},
+		Latency:       "http_request_duration_seconds",
+		Quantiles:     []float64{0.99},
+		Objective:     0.999,
+		Labels:        []string{"route"},
+	}))
+	require.NoError(t, m.DeclareSLI(SLI{
+		Name:      "jobs",
+		Requests:  "jobs_total",
+		Errors:    "jobs_failed_total",
+		Objective: 0.99,
+	}))
+
+	data, err := m.PrometheusRules(RuleOpts{Namespace: "monitoring", Labels: map[string]string{"release": "prometheus"}})
+	require.NoError(t, err)
+
+	// The golden file is the reviewed output.  Check the changes with
+	// promtool check rules when it is updated with -update.
+	golden := filepath.Join("testdata", "prometheus_rules.yaml")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, data, 0o600))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+
+	var rule prometheusRule
+	require.NoError(t, yaml.Unmarshal(data, &rule))
+	checkRules(t, rule)
+
+	// The rules survive a round trip without losing any fields.
+	roundTrip, err := yaml.Marshal(rule)
+	require.NoError(t, err)
+	assert.YAMLEq(t, string(data), string(roundTrip))
+
+	assert.Equal(t, "PrometheusRule", rule.Kind)
+	assert.Equal(t, DefaultRuleName, rule.Metadata.Name)
+	assert.Equal(t, "monitoring", rule.Metadata.Namespace)
+	assert.Equal(t, map[string]string{"release": "prometheus"}, rule.Metadata.Labels)
+
+	groups := rule.Spec.Groups
+	require.Len(t, groups, 4)
+	assert.Equal(t, "api-sli-recording", groups[0].Name)
+	assert.Equal(t, "api-slo-alerts", groups[1].Name)
+	assert.Equal(t, "jobs-sli-recording", groups[2].Name)
+	assert.Equal(t, "jobs-slo-alerts", groups[3].Name)
+
+	api := groups[0].Rules
+	assert.Equal(t, "sum by (route) (rate(api_http_requests_total[5m]))", api[0].Expr)
+	assert.Equal(t, `sum by (route) (rate(api_http_requests_total{code=~"5.."}[5m]))`, api[1].Expr)
+	assert.Equal(t, "sli:error_ratio:rate1h", api[4].Record)
+	assert.Equal(t, `sum by (route) (rate(api_http_requests_total{code=~"5.."}[1h]))`+"\n/\n"+
+		"sum by (route) (rate(api_http_requests_total[1h]))", api[4].Expr)
+	latency := api[len(api)-1]
+	assert.Equal(t, "sli:latency:quantile5m", latency.Record)
+	assert.Equal(t, "histogram_quantile(0.99, sum by (le, route) (rate(api_http_request_duration_seconds_bucket[5m])))", latency.Expr)
+	assert.Equal(t, map[string]string{"sli": "api", "quantile": "0.99"}, latency.Labels)
+
+	jobs := groups[2].Rules
+	assert.Equal(t, "sum(rate(api_jobs_failed_total[5m]))", jobs[1].Expr)
+	// No latency quantiles are recorded without a histogram.
+	assert.Len(t, jobs, 2+len(sliWindows))
+
+	alerts := groups[3].Rules
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "SLOErrorBudgetBurn", alerts[0].Alert)
+	assert.Equal(t, map[string]string{"sli": "jobs", "severity": "page"}, alerts[0].Labels)
+	assert.Equal(t, `(
+  sli:error_ratio:rate1h{sli="jobs"} > (14.4 * (1 - 0.99))
+and
+  sli:error_ratio:rate5m{sli="jobs"} > (14.4 * (1 - 0.99))
+)
+or
+(
+  sli:error_ratio:rate6h{sli="jobs"} > (6 * (1 - 0.99))
+and
+  sli:error_ratio:rate30m{sli="jobs"} > (6 * (1 - 0.99))
+)`, alerts[0].Expr)
+	assert.Equal(t, map[string]string{"sli": "jobs", "severity": "ticket"}, alerts[1].Labels)
+	assert.Contains(t, alerts[1].Expr, `sli:error_ratio:rate3d{sli="jobs"} > (1 * (1 - 0.99))`)
+}
+
+func TestDeclareSLIInvalid(t *testing.T) {
+	tests := map[string]SLI{
+		"no name":        {Requests: "requests_total", Errors: "errors_total", Objective: 0.9},
+		"no requests":    {Name: "api", Errors: "errors_total", Objective: 0.9},
+		"no errors":      {Name: "api", Requests: "requests_total", Objective: 0.9},
+		"both errors":    {Name: "api", Requests: "requests_total", Errors: "errors_total", ErrorMatchers: []string{`code="500"`}, Objective: 0.9},
+		"bad metric":     {Name: "api", Requests: "requests-total", Errors: "errors_total", Objective: 0.9},
+		"selector":       {Name: "api", Requests: `requests_total{job="a"}`, ErrorMatchers: []string{`code=~"5.."`}, Objective: 0.9},
+		"bad matcher":    {Name: "api", Requests: "requests_total", ErrorMatchers: []string{"code"}, Objective: 0.9},
+		"bad objective":  {Name: "api", Requests: "requests_total", Errors: "errors_total", Objective: 1},
+		"no objective":   {Name: "api", Requests: "requests_total", Errors: "errors_total"},
+		"bad quantile":   {Name: "api", Requests: "requests_total", Errors: "errors_total", Objective: 0.9, Quantiles: []float64{99}},
+		"bad label name": {Name: "api", Requests: "requests_total", Errors: "errors_total", Objective: 0.9, Labels: []string{"__name__"}},
+	}
+
+	for name, sli := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := New(MetricsOpts{Registry: prometheus.NewRegistry()})
+			assert.ErrorIs(t, m.DeclareSLI(sli), ErrInvalidSLI)
+		})
+	}
+
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry()})
+	sli := SLI{Name: "api", Requests: "requests_total", Errors: "errors_total", Objective: 0.9}
+	require.NoError(t, m.DeclareSLI(sli))
+	// The SLIs are shared by the derived metrics.
+	assert.ErrorIs(t, m.WithPrefix("other").DeclareSLI(sli), ErrInvalidSLI)
+}
+
+var (
+	update = flag.Bool("update", false, "update the golden files") //nolint:gochecknoglobals
+
+	promDurationRegexp = regexp.MustCompile(`^\[[0-9]+[smhdwy]\]$`)       //nolint:gochecknoglobals
+	rangeRegexp        = regexp.MustCompile(`\[[^\]]*\]`)                 //nolint:gochecknoglobals
+	recordRefRegexp    = regexp.MustCompile(`[a-z_]+:[a-z_]+:[a-z0-9_]+`) //nolint:gochecknoglobals
+)
+
+// checkRules performs the structural checks of promtool check rules.  The
+// expressions are only checked for balanced brackets and valid range
+// durations; the golden file covers the rest.
+func checkRules(t *testing.T, rule prometheusRule) {
+	t.Helper()
+
+	records := make(map[string]bool)
+	groups := make(map[string]bool)
+	for _, g := range rule.Spec.Groups {
+		require.NotEmpty(t, g.Name)
+		assert.False(t, groups[g.Name], "duplicate group %s", g.Name)
+		groups[g.Name] = true
+
+		for _, r := range g.Rules {
+			assert.True(t, (r.Record == "") != (r.Alert == ""), "exactly one of record and alert: %+v", r)
+			if r.Record != "" {
+				assert.Regexp(t, metricNameRegexp, r.Record)
+				records[r.Record] = true
+			}
+			if r.Alert != "" {
+				assert.Regexp(t, labelNameRegexp, r.Alert)
+				assert.NotEmpty(t, r.Annotations["summary"])
+			}
+			if r.For != "" {
+				_, err := model.ParseDuration(r.For)
+				assert.NoError(t, err)
+			}
+			for name := range r.Labels {
+				assert.NoError(t, validateLabelName(name))
+			}
+
+			require.NotEmpty(t, r.Expr)
+			assertBalanced(t, r.Expr)
+			for _, window := range rangeRegexp.FindAllString(r.Expr, -1) {
+				assert.Regexp(t, promDurationRegexp, window, r.Expr)
+			}
+		}
+	}
+
+	// Every recorded series used by the alerts is recorded.
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			if r.Alert == "" {
+				continue
+			}
+			for _, ref := range recordRefRegexp.FindAllString(r.Expr, -1) {
+				assert.True(t, records[ref], "%s is not recorded", ref)
+			}
+		}
+	}
+}
+
+// assertBalanced checks that the brackets outside of the strings of the
+// expression are balanced.
+func assertBalanced(t *testing.T, expr string) {
+	t.Helper()
+
+	pairs := map[rune]rune{')': '(', '}': '{', ']': '['}
+	var stack []rune
+	inString := false
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			inString = !inString
+		case inString:
+		case strings.ContainsRune("({[", r):
+			stack = append(stack, r)
+		case strings.ContainsRune(")}]", r):
+			if !assert.NotEmpty(t, stack, expr) || !assert.Equal(t, pairs[r], stack[len(stack)-1], expr) {
+				return
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	assert.Empty(t, stack, expr)
+	assert.False(t, inString, expr)
+}
@@ -0,0 +1,336 @@
+package strata
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`) //nolint:gochecknoglobals
+
+// Schema is a declarative description of the metrics exposed by a service.
+// It is loaded with LoadSchema and applied using MetricsOpts.  Example:
+//
+//	strict: true
+//	metrics:
+//	  - name: api_requests_total
+//	    type: counter
+//	    help: Requests served by the API.
+//	    labels: [method, code]
+//	    maxCardinality: 50
+//	  - name: api_request_duration_seconds
+//	    type: histogram
+//	    unit: seconds
+//	    labels: [method]
+//	    buckets: [0.05, 0.1, 0.25, 0.5, 1]
+type Schema struct {
+	// Strict rejects the metrics that are not declared in the schema.
+	Strict bool `yaml:"strict"`
+	// Metrics are the declared metrics.
+	Metrics []MetricSchema `yaml:"metrics"`
+}
+
+// MetricSchema declares a single metric.
+type MetricSchema struct {
+	// Name is the full name of the metric including any prefixes.
+	Name string `yaml:"name"`
+	// Type is one of counter, gauge, histogram, summary, info or stateset.
+	// Info and stateset metrics are not registered until they are first used
+	// because their label values and states are not declared.
+	Type MetricType `yaml:"type"`
+	// Help is the help string of the metric.  By default DefaultHelpString is
+	// used.
+	Help string `yaml:"help"`
+	// Unit is the unit of the metric.  When set, the name must end with the
+	// unit, followed by _total for counters.
+	Unit string `yaml:"unit"`
+	// Labels are the variable label names in the order the values are passed.
+	// The labels of an info metric are the keys of its labels in any order,
+	// and the state label of a stateset is not included.
+	Labels []string `yaml:"labels"`
+	// Buckets are the buckets of a histogram.  By default the buckets from
+	// MetricsOpts are used.
+	Buckets []float64 `yaml:"buckets"`
+	// Objectives are the quantile rank estimates of a summary.  By default the
+	// objectives from MetricsOpts are used.
+	Objectives []Objective `yaml:"objectives"`
+	// MaxCardinality is the maximum number of label value combinations.
+	// Observations for new combinations beyond the limit are rejected.  By
+	// default the cardinality is not limited.
+	MaxCardinality int `yaml:"maxCardinality"`
+}
+
+// Objective is a quantile rank estimate of a summary with its absolute error.
+type Objective struct {
+	Quantile float64 `yaml:"quantile"`
+	Error    float64 `yaml:"error"`
+}
+
+// LoadSchema reads and validates a schema from a YAML or JSON file.
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %w", err)
+	}
+
+	return ParseSchema(data)
+}
+
+// ParseSchema parses and validates a schema from a YAML or JSON document.
+func ParseSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+// Validate checks that the declared metrics are well formed and unique.
+func (s *Schema) Validate() error {
+	seen := make(map[string]bool, len(s.Metrics))
+	for _, m := range s.Metrics {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+		}
+
+		if seen[m.Name] {
+			return fmt.Errorf("%w: %s is declared more than once", ErrInvalidSchema, m.Name)
+		}
+		seen[m.Name] = true
+	}
+
+	return nil
+}
+
+func (m *MetricSchema) validate() error {
+	if !metricNameRegexp.MatchString(m.Name) {
+		return fmt.Errorf("invalid metric name %q", m.Name)
+	}
+
+	switch m.Type {
+	case CounterType, GaugeType, HistogramType, SummaryType, InfoType, StateSetType:
+	default:
+		return fmt.Errorf("%s: unsupported type %q", m.Name, m.Type)
+	}
+
+	if m.Unit != "" {
+		suffix := "_" + m.Unit
+		if m.Type == CounterType {
+			suffix += "_total"
+		}
+		if !strings.HasSuffix(m.Name, suffix) {
+			return fmt.Errorf("%s: name must end with %s", m.Name, suffix)
+		}
+	}
+
+	if len(m.Buckets) > 0 && m.Type != HistogramType {
+		return fmt.Errorf("%s: buckets are only supported by histograms", m.Name)
+	}
+
+	if len(m.Objectives) > 0 && m.Type != SummaryType {
+		return fmt.Errorf("%s: objectives are only supported by summaries", m.Name)
+	}
+
+	for _, o := range m.Objectives {
+		if o.Quantile < 0 || o.Quantile > 1 {
+			return fmt.Errorf("%s: quantile %v is not between 0 and 1", m.Name, o.Quantile)
+		}
+	}
+
+	if m.MaxCardinality < 0 {
+		return fmt.Errorf("%s: maxCardinality must not be negative", m.Name)
+	}
+
+	if m.MaxCardinality > 0 && (m.Type == InfoType || m.Type == StateSetType) {
+		return fmt.Errorf("%s: maxCardinality is not supported by %s metrics", m.Name, m.Type)
+	}
+
+	return nil
+}
+
+func (m *MetricSchema) help() string {
+	if m.Help == "" {
+		return DefaultHelpString
+	}
+	return m.Help
+}
+
+// schemaIndex enforces a schema for the metrics in a store.  All of the
+// methods are safe to call on a nil schemaIndex, which accepts every metric.
+type schemaIndex struct {
+	strict   bool
+	metrics  map[string]*MetricSchema
+	series   map[string]map[string]struct{}
+	internal map[string]struct{}
+	sync.Mutex
+}
+
+func newSchemaIndex(schema *Schema) *schemaIndex {
+	if schema == nil {
+		return nil
+	}
+
+	idx := &schemaIndex{
+		strict:   schema.Strict,
+		metrics:  make(map[string]*MetricSchema, len(schema.Metrics)),
+		series:   make(map[string]map[string]struct{}),
+		internal: make(map[string]struct{}),
+	}
+
+	for i := range schema.Metrics {
+		m := &schema.Metrics[i]
+		idx.metrics[m.Name] = m
+	}
+
+	return idx
+}
+
+// lookup returns the declaration of the metric, or nil if it isn't declared.
+func (s *schemaIndex) lookup(name string) *MetricSchema {
+	if s == nil {
+		return nil
+	}
+	return s.metrics[name]
+}
+
+// exempt marks the metrics that are managed by strata itself.  They are
+// always accepted in strict mode.
+func (s *schemaIndex) exempt(names ...string) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, name := range names {
+		s.internal[name] = struct{}{}
+	}
+}
+
+// check returns ErrUndeclaredMetric if strict mode is enabled and the metric
+// is not declared, or ErrSchemaMismatch if the type or labels don't match the
+// declaration.
+func (s *schemaIndex) check(name string, mtype MetricType, labels []string) error {
+	if s == nil {
+		return nil
+	}
+
+	s.Lock()
+	_, internal := s.internal[name]
+	s.Unlock()
+	if internal {
+		return nil
+	}
+
+	m := s.metrics[name]
+	if m == nil {
+		if s.strict {
+			return ErrUndeclaredMetric
+		}
+		return nil
+	}
+
+	declared := m.Labels
+	if mtype == InfoType {
+		// The labels of info metrics are passed as a map.
+		declared = slices.Clone(declared)
+		slices.Sort(declared)
+	}
+
+	if m.Type != mtype || !slices.Equal(declared, labels) {
+		return ErrSchemaMismatch
+	}
+
+	return nil
+}
+
+// admit tracks the label values of the metric and returns
+// ErrCardinalityLimit if they are a new combination beyond the declared limit.
+func (s *schemaIndex) admit(name string, lv []string) error {
+	m := s.lookup(name)
+	if m == nil || m.MaxCardinality == 0 {
+		return nil
+	}
+
+	key := strings.Join(lv, "\xff")
+
+	s.Lock()
+	defer s.Unlock()
+
+	series, ok := s.series[name]
+	if !ok {
+		series = make(map[string]struct{})
+		s.series[name] = series
+	}
+
+	if _, ok := series[key]; ok {
+		return nil
+	}
+
+	if len(series) >= m.MaxCardinality {
+		return ErrCardinalityLimit
+	}
+
+	series[key] = struct{}{}
+	return nil
+}
+
+// registerSchema registers the metrics declared in the schema.  Metrics
+// without labels are initialized so they are exposed before they are first
+// observed.  The collectors of counters and gauges are replaced if the metric
+// is registered with a callback instead.
+func (m *Metrics) registerSchema(schema *Schema) {
+	for _, d := range schema.Metrics {
+		var err error
+		switch d.Type {
+		case CounterType:
+			var vec *CounterVec
+			if vec, err = m.store.getCounter(m.registerer, d.Name, d.Labels...); err == nil {
+				if len(d.Labels) == 0 {
+					vec.vec.WithLabelValues()
+				}
+				m.store.declare(d.Name, func() {
+					m.registerer.Unregister(vec.vec)
+					delete(m.store.counters, d.Name)
+				})
+			}
+		case GaugeType:
+			var vec *GaugeVec
+			if vec, err = m.store.getGauge(m.registerer, d.Name, d.Labels...); err == nil {
+				if len(d.Labels) == 0 {
+					vec.vec.WithLabelValues()
+				}
+				m.store.declare(d.Name, func() {
+					m.registerer.Unregister(vec.vec)
+					delete(m.store.gauges, d.Name)
+				})
+			}
+		case HistogramType:
+			var vec *HistogramVec
+			if vec, err = m.store.getHistogram(m.registerer, d.Name, m.histogramBuckets, d.Labels...); err == nil && len(d.Labels) == 0 {
+				vec.vec.WithLabelValues()
+			}
+		case SummaryType:
+			var vec *SummaryVec
+			if vec, err = m.store.getSummary(m.registerer, d.Name, *m.summaryOpts, d.Labels...); err == nil && len(d.Labels) == 0 {
+				vec.vec.WithLabelValues()
+			}
+		}
+
+		if err != nil {
+			m.logger.Error(err, "unable to register schema metric", "name", d.Name)
+			m.emitError(err, d.Name, "schema")
+		}
+	}
+}
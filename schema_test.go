@@ -0,0 +1,274 @@
+package strata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+strict: true
+metrics:
+  - name: api_requests_total
+    type: counter
+    help: Requests served by the API.
+    labels: [code]
+    maxCardinality: 2
+  - name: api_request_duration_seconds
+    type: histogram
+    unit: seconds
+    buckets: [0.1, 1]
+  - name: api_latency_seconds
+    type: summary
+    objectives:
+      - {quantile: 0.5, error: 0.05}
+  - name: api_queue_depth
+    type: gauge
+`
+
+func TestLoadSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testSchema), 0o600))
+
+	schema, err := LoadSchema(path)
+	require.NoError(t, err)
+	assert.True(t, schema.Strict)
+	require.Len(t, schema.Metrics, 4)
+	assert.Equal(t, CounterType, schema.Metrics[0].Type)
+	assert.Equal(t, []string{"code"}, schema.Metrics[0].Labels)
+	assert.Equal(t, []float64{0.1, 1}, schema.Metrics[1].Buckets)
+	assert.Equal(t, []Objective{{Quantile: 0.5, Error: 0.05}}, schema.Metrics[2].Objectives)
+
+	// JSON documents are supported.
+	schema, err = ParseSchema([]byte(`{"metrics": [{"name": "a_total", "type": "counter", "labels": ["code"]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "a_total", schema.Metrics[0].Name)
+
+	_, err = LoadSchema(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`metrics: [{name: "0invalid", type: counter}]`,
+		`metrics: [{name: a, type: unknown}]`,
+		`metrics: [{name: a_total, type: counter}, {name: a_total, type: counter}]`,
+		`metrics: [{name: a_total, type: counter, unit: seconds}]`,
+		`metrics: [{name: a, type: gauge, buckets: [1]}]`,
+		`metrics: [{name: a, type: histogram, objectives: [{quantile: 0.5, error: 0.1}]}]`,
+		`metrics: [{name: a, type: summary, objectives: [{quantile: 2, error: 0.1}]}]`,
+		`metrics: [{name: a, type: gauge, maxCardinality: -1}]`,
+		`metrics: {`,
+	} {
+		_, err := ParseSchema([]byte(doc))
+		assert.ErrorIs(t, err, ErrInvalidSchema, doc)
+	}
+}
+
+func TestMetricsSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	m := New(MetricsOpts{
+		Registry:   reg,
+		Schema:     schema,
+		Collectors: &CollectorsOpts{DisableGoCollector: true, DisableProcessCollector: true},
+	}).WithPrefix("api")
+
+	// The declared metrics are registered before they are observed.
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP api_queue_depth created automagically by strata
+# TYPE api_queue_depth gauge
+api_queue_depth 0
+# HELP api_request_duration_seconds created automagically by strata
+# TYPE api_request_duration_seconds histogram
+api_request_duration_seconds_bucket{le="0.1"} 0
+api_request_duration_seconds_bucket{le="1"} 0
+api_request_duration_seconds_bucket{le="+Inf"} 0
+api_request_duration_seconds_sum 0
+api_request_duration_seconds_count 0
+`), "api_queue_depth", "api_request_duration_seconds"))
+
+	requests := m.WithLabels("code")
+	requests.CounterInc("requests_total", "200")
+	requests.CounterInc("requests_total", "500")
+	requests.CounterInc("requests_total", "200")
+	// The third label value exceeds the cardinality limit.
+	requests.CounterInc("requests_total", "503")
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP api_requests_total Requests served by the API.
+# TYPE api_requests_total counter
+api_requests_total{code="200"} 2
+api_requests_total{code="500"} 1
+`), "api_requests_total"))
+
+	// Undeclared metrics are rejected in strict mode.
+	m.CounterInc("undeclared_total")
+	// Metrics that don't match their declaration are rejected.
+	m.GaugeSet("requests_total", 1)
+	m.WithLabels("instance").GaugeSet("queue_depth", 1, "a")
+	assert.Equal(t, 0, testutil.CollectAndCount(reg, "api_undeclared_total"))
+}
+
+func TestMetricsSchemaTimers(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	m := New(MetricsOpts{Registry: reg, Schema: schema})
+
+	// Rejected timers are usable and don't observe anything.
+	assert.NotPanics(t, func() {
+		m.HistogramTimer("undeclared_seconds").ObserveDuration()
+		m.SummaryTimer("api_queue_depth").ObserveDuration()
+		m.Histogram("undeclared_seconds").Timer().ObserveDuration()
+		m.Summary("undeclared_seconds").Timer().ObserveDuration()
+	})
+	assert.Equal(t, 0, testutil.CollectAndCount(reg, "undeclared_seconds"))
+
+	m.HistogramTimer("api_request_duration_seconds").ObserveDuration()
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "api_request_duration_seconds"))
+}
+
+func TestMetricsSchemaInfoStateSet(t *testing.T) {
+	schema, err := ParseSchema([]byte(`
+strict: true
+metrics:
+  - name: app_release_info
+    type: info
+    labels: [version, channel]
+  - name: app_breaker_state
+    type: stateset
+    labels: [backend]
+`))
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	m := New(MetricsOpts{
+		Registry:     reg,
+		Schema:       schema,
+		PanicOnError: true,
+		BuildInfo:    &BuildInfoOpts{},
+	}).WithPrefix("app")
+
+	m.Info("release", map[string]string{"channel": "stable", "version": "v1.2.3"})
+	require.NoError(t, m.WithLabels("backend").StateSet("breaker_state", []string{"closed", "open"}).SetState("open", "db"))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "app_release_info"))
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "app_breaker_state"))
+
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.Info("undeclared", map[string]string{"version": "v1.2.3"})
+	})
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.StateSet("undeclared_state", []string{"on", "off"})
+	})
+	assert.PanicsWithValue(t, ErrSchemaMismatch, func() {
+		m.Info("release", map[string]string{"version": "v1.2.3"})
+	})
+	assert.PanicsWithValue(t, ErrSchemaMismatch, func() {
+		m.StateSet("breaker_state", []string{"closed", "open"})
+	})
+
+	_, err = ParseSchema([]byte(`metrics: [{name: a_state, type: stateset, maxCardinality: 2}]`))
+	assert.ErrorIs(t, err, ErrInvalidSchema)
+}
+
+func TestMetricsSchemaPanicOnError(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	m := New(MetricsOpts{
+		Registry:     prometheus.NewRegistry(),
+		Schema:       schema,
+		PanicOnError: true,
+	})
+
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.CounterInc("undeclared_total")
+	})
+	assert.PanicsWithValue(t, ErrSchemaMismatch, func() {
+		m.CounterInc("api_queue_depth")
+	})
+
+	requests := m.WithLabels("code")
+	requests.CounterInc("api_requests_total", "200")
+	requests.CounterInc("api_requests_total", "500")
+	assert.PanicsWithValue(t, ErrCardinalityLimit, func() {
+		requests.CounterInc("api_requests_total", "503")
+	})
+
+	// The metrics managed by strata are always accepted, but not the other
+	// metrics in the strata namespace.
+	assert.NotPanics(t, func() {
+		m.internal("check", "type").GaugeSet(healthCheckStatusName, 1, "db", readinessCheck)
+	})
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.WithPrefix("strata").CounterInc("jobs_total")
+	})
+}
+
+func TestMetricsSchemaFuncs(t *testing.T) {
+	schema, err := ParseSchema([]byte(`
+strict: true
+metrics:
+  - name: pool_connections
+    type: gauge
+    help: Open connections of the pool.
+    labels: [pool]
+  - name: api_queue_depth
+    type: gauge
+`))
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	m := New(MetricsOpts{Registry: reg, Schema: schema, PanicOnError: true})
+
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.GaugeFunc("undeclared", func() float64 { return 1 })
+	})
+	assert.PanicsWithValue(t, ErrSchemaMismatch, func() {
+		m.CounterFunc("api_queue_depth", func() float64 { return 1 })
+	})
+	assert.PanicsWithValue(t, ErrSchemaMismatch, func() {
+		m.GaugeFuncVec("pool_connections", []string{"name"}, func() map[string]float64 { return nil })
+	})
+
+	m.GaugeFuncVec("pool_connections", []string{"pool"}, func() map[string]float64 {
+		return map[string]float64{"primary": 3}
+	})
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP pool_connections Open connections of the pool.
+# TYPE pool_connections gauge
+pool_connections{pool="primary"} 3
+`), "pool_connections"))
+}
+
+func TestMetricsSchemaRecorder(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	rec := NewRecorder()
+	m := New(MetricsOpts{Recorder: rec, Schema: schema, PanicOnError: true})
+
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.CounterInc("undeclared_total")
+	})
+	assert.PanicsWithValue(t, ErrSchemaMismatch, func() {
+		m.HistogramTimer("api_queue_depth")
+	})
+	assert.PanicsWithValue(t, ErrUndeclaredMetric, func() {
+		m.GaugeFunc("undeclared", func() float64 { return 1 })
+	})
+
+	m.GaugeSet("api_queue_depth", 3)
+	require.Len(t, rec.Events(), 1)
+	assert.Equal(t, "api_queue_depth", rec.Events()[0].Name)
+}
@@ -0,0 +1,138 @@
+package strata
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	scrapeErrorGather     = "gather"
+	scrapeErrorEncode     = "encode"
+	scrapeErrorWrite      = "write"
+	scrapeErrorBadRequest = "bad_request"
+	scrapeErrorLimit      = "limit"
+	scrapeErrorTimeout    = "timeout"
+)
+
+// scrapeMetrics are the metrics used to instrument the exposition handlers.
+// They are shared by all of the handlers that register with the same
+// registerer and are partitioned by the endpoint label.
+type scrapeMetrics struct {
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	errors   *prometheus.CounterVec
+}
+
+// newScrapeMetrics creates and registers the scrape metrics.  If the metrics
+// have already been registered, the existing collectors are used.  A nil
+// registerer disables the instrumentation.
+func newScrapeMetrics(reg prometheus.Registerer) *scrapeMetrics {
+	if reg == nil {
+		return nil
+	}
+
+	s := &scrapeMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "strata_scrape_duration_seconds",
+			Help:    "Duration of the scrapes served by strata.",
+			Buckets: DefBuckets,
+		}, []string{"endpoint"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "strata_scrape_size_bytes",
+			Help:    "Size of the scrape responses served by strata after compression.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+		}, []string{"endpoint"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "strata_scrape_requests_in_flight",
+			Help: "Number of scrapes currently being served by strata.",
+		}, []string{"endpoint"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "strata_scrape_errors_total",
+			Help: "Number of scrapes served by strata that failed, partitioned by cause.",
+		}, []string{"endpoint", "cause"}),
+	}
+
+	s.duration = registerOrExisting(reg, s.duration)
+	s.size = registerOrExisting(reg, s.size)
+	s.inFlight = registerOrExisting(reg, s.inFlight)
+	s.errors = registerOrExisting(reg, s.errors)
+
+	return s
+}
+
+// registerOrExisting registers the collector and returns it, or returns the
+// collector that has already been registered with the same descriptor.
+func registerOrExisting[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+	}
+	return c
+}
+
+// observer returns the observer for the endpoint.  It is safe to call on a nil
+// scrapeMetrics.
+func (s *scrapeMetrics) observer(endpoint string) *scrapeObserver {
+	if s == nil {
+		return nil
+	}
+
+	return &scrapeObserver{
+		duration: s.duration.WithLabelValues(endpoint),
+		size:     s.size.WithLabelValues(endpoint),
+		inFlight: s.inFlight.WithLabelValues(endpoint),
+		errors:   s.errors.MustCurryWith(prometheus.Labels{"endpoint": endpoint}),
+	}
+}
+
+// scrapeObserver records the scrapes of a single endpoint.  All of the methods
+// are safe to call on a nil scrapeObserver.
+type scrapeObserver struct {
+	duration prometheus.Observer
+	size     prometheus.Observer
+	inFlight prometheus.Gauge
+	errors   *prometheus.CounterVec
+}
+
+// start records the beginning of a scrape and returns the function that
+// records its completion.
+func (o *scrapeObserver) start() func(size int64) {
+	if o == nil {
+		return func(int64) {}
+	}
+
+	begin := time.Now()
+	o.inFlight.Inc()
+	return func(size int64) {
+		o.inFlight.Dec()
+		o.duration.Observe(time.Since(begin).Seconds())
+		o.size.Observe(float64(size))
+	}
+}
+
+func (o *scrapeObserver) error(cause string) {
+	if o == nil {
+		return
+	}
+	o.errors.WithLabelValues(cause).Inc()
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
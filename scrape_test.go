@@ -0,0 +1,102 @@
+package strata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerScrapeMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(MetricsOpts{Registry: reg, PanicOnError: true})
+	m.GaugeSet("queue_depth", 3)
+
+	h := HandlerWithOpts(m, HandlerOpts{})
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/metrics?match[]=invalid`, nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP strata_scrape_errors_total Number of scrapes served by strata that failed, partitioned by cause.
+# TYPE strata_scrape_errors_total counter
+strata_scrape_errors_total{cause="bad_request",endpoint="/metrics"} 1
+# HELP strata_scrape_requests_in_flight Number of scrapes currently being served by strata.
+# TYPE strata_scrape_requests_in_flight gauge
+strata_scrape_requests_in_flight{endpoint="/metrics"} 0
+`), "strata_scrape_errors_total", "strata_scrape_requests_in_flight"))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "strata_scrape_duration_seconds":
+			assert.Equal(t, uint64(3), mf.GetMetric()[0].GetHistogram().GetSampleCount())
+		case "strata_scrape_size_bytes":
+			assert.Equal(t, uint64(3), mf.GetMetric()[0].GetHistogram().GetSampleCount())
+			assert.Greater(t, mf.GetMetric()[0].GetHistogram().GetSampleSum(), 0.0)
+		}
+	}
+
+	// A second handler shares the scrape metrics.
+	h2 := HandlerWithOpts(m, HandlerOpts{Endpoint: "/metrics/other"})
+	rec = httptest.NewRecorder()
+	h2.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/other", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "strata_scrape_requests_in_flight"))
+}
+
+func TestHandlerMaxRequestsInFlight(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(MetricsOpts{Registry: reg, PanicOnError: true})
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	var once sync.Once
+	m.GaugeFunc("blocking", func() float64 {
+		once.Do(func() { close(started) })
+		<-block
+		return 0
+	})
+
+	h := HandlerWithOpts(m, HandlerOpts{MaxRequestsInFlight: 1})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "scrape did not start")
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	close(block)
+	wg.Wait()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(
+		newScrapeMetrics(reg).errors.WithLabelValues("/metrics", scrapeErrorLimit),
+	))
+}
@@ -1,19 +1,27 @@
 package strata
 
 import (
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const unixPrefix = "unix://"
+
 type TLSOpts struct {
 	// CertFile is the path to the file containing the SSL certificate or
 	// certificate bundle.
@@ -22,23 +30,87 @@ type TLSOpts struct {
 	KeyFile string
 	// InsecureSkipVerify controls whether a client verifies the server's
 	// certificate chain and host name.
+	//
+	// Deprecated: InsecureSkipVerify is a client setting and has no effect on
+	// the server.  Use ClientCAFile and ClientAuth to verify clients.
 	InsecureSkipVerify bool
 	// MinVersion contains the minimum TLS version that is acceptable.  By
 	// default TLS 1.3 is used.
 	MinVersion uint16
+	// ClientCAFile is the path to the file containing the PEM encoded
+	// certificate authorities used to verify client certificates.
+	ClientCAFile string
+	// ClientAuth is the policy the server follows for TLS client
+	// authentication.  By default tls.RequireAndVerifyClientCert is used when
+	// ClientCAFile is set.
+	ClientAuth tls.ClientAuthType
+	// ReloadInterval is the interval used to check the certificate and key
+	// files for changes.  When a change is detected the pair is reloaded
+	// without restarting the server.  By default the files are not watched.
+	ReloadInterval time.Duration
+	// ReloadOnSIGHUP reloads the certificate and key pair when the process
+	// receives a SIGHUP.
+	ReloadOnSIGHUP bool
 }
 
 type ServerOpts struct {
 	// BindAddr is the address the promethus collector will listen on for
-	// connections.
+	// connections.  An address in the form unix:///path/to/socket listens on a
+	// Unix domain socket and Port is ignored.
 	BindAddr string
+	// Listener is used to accept connections instead of listening on BindAddr
+	// and Port.  The server closes the listener when it is shut down.
+	Listener net.Listener
 	// BaseContext
 	// Path is the path used by the HTTP server.
 	Path string
 	// Port is the path used by the HTTP server.
 	Port int
+	// EphemeralPort binds port 0 so the operating system chooses a free port.
+	// The address can be retrieved with Addr once the server is listening.
+	EphemeralPort bool
 	// TLS
 	TLS *TLSOpts
+	// Auth defines basic and bearer token authentication for the metrics
+	// endpoint.  By default authentication is disabled.
+	Auth *AuthOpts
+	// Debug defines the options for the debug endpoints, such as pprof and
+	// expvar.  By default the debug endpoints are not mounted.
+	Debug *DebugOpts
+	// EnableHealthChecks mounts the /healthz, /readyz and /livez endpoints
+	// which run the checks added with AddReadinessCheck and AddLivenessCheck.
+	// The endpoints are not authenticated so they can be used by probes.
+	EnableHealthChecks bool
+	// EnableOpenMetrics enables the negotiation of the OpenMetrics exposition
+	// format.  When OpenMetrics is negotiated, info and stateset metrics are
+	// exposed with their native types.
+	EnableOpenMetrics bool
+	// ExcludeFamilies is a list of metric family names that are left out of
+	// the metrics endpoint unless they are explicitly requested with the
+	// name[] query parameter.
+	ExcludeFamilies []string
+	// MaxRequestsInFlight limits the number of concurrent scrapes served by
+	// each metrics endpoint.  Scrapes that exceed the limit receive a 503
+	// response.  By default the number of scrapes is not limited.
+	MaxRequestsInFlight int
+	// GzipLevel is the compression level used for gzip encoded responses.
+	// The levels of compress/gzip are supported.  By default
+	// gzip.DefaultCompression is used.
+	GzipLevel int
+	// ZstdLevel is the compression level used for zstd encoded responses
+	// using the levels of the zstd command line tool.  By default the fastest
+	// level is used.
+	ZstdLevel int
+	// GatherCacheTTL shares the gathered metrics between the scrapes that
+	// arrive within the duration, for example when several replicas of
+	// Prometheus scrape the same target.  Concurrent scrapes wait for the
+	// gather that is in progress instead of running the collectors again.  By
+	// default the metrics are gathered for every scrape.
+	GatherCacheTTL time.Duration
+	// Endpoints maps additional paths to the Metrics that are exposed on them.
+	// Each path serves the registry of its Metrics, which allows families to
+	// be scraped at different intervals.  See Metrics.WithRegistry.
+	Endpoints map[string]*Metrics
 	// TerminationGracePeriod is the amount of time that the server will wait
 	// before stopping the HTTP server.  This grace period allows any prometheus
 	// scrapers time to scrape.
@@ -46,81 +118,465 @@ type ServerOpts struct {
 }
 
 type Server struct {
+	addr                   net.Addr
+	auth                   *AuthOpts
 	bindAddr               string
+	debug                  *DebugOpts
+	debugAddr              net.Addr
+	done                   chan struct{}
+	endpoints              map[string]*Metrics
+	draining               atomic.Bool
+	enableHealthChecks     bool
+	enableOpenMetrics      bool
+	excludeFamilies        []string
+	gatherCacheTTL         time.Duration
+	gzipLevel              int
+	zstdLevel              int
+	listener               net.Listener
 	logger                 Logger
+	maxRequestsInFlight    int
+	onReady                func()
 	path                   string
 	port                   int
-	stopChan               chan struct{}
-	stopOnce               sync.Once
+	ready                  chan struct{}
+	readyOnce              sync.Once
+	scraped                chan struct{}
+	scrapedOnce            sync.Once
+	servers                []*http.Server
+	shutdownErr            error
+	shutdownOnce           sync.Once
+	stopped                bool
 	tlsCertFile            string
 	tlsKeyFile             string
 	tlsInsecureSkipVerify  bool
 	tlsMinVersion          uint16
+	tlsClientCAFile        string
+	tlsClientAuth          tls.ClientAuthType
+	tlsReloadInterval      time.Duration
+	tlsReloadOnSIGHUP      bool
 	terminationGracePeriod time.Duration
+	sync.RWMutex
 }
 
 func newServer(opts ServerOpts) *Server {
 	opts = defaultedServer(opts)
 	return &Server{
+		auth:                   opts.Auth,
 		bindAddr:               opts.BindAddr,
+		debug:                  opts.Debug,
+		done:                   make(chan struct{}),
+		endpoints:              opts.Endpoints,
+		enableHealthChecks:     opts.EnableHealthChecks,
+		enableOpenMetrics:      opts.EnableOpenMetrics,
+		excludeFamilies:        opts.ExcludeFamilies,
+		gatherCacheTTL:         opts.GatherCacheTTL,
+		gzipLevel:              opts.GzipLevel,
+		zstdLevel:              opts.ZstdLevel,
+		listener:               opts.Listener,
 		logger:                 logr.New(nil),
+		maxRequestsInFlight:    opts.MaxRequestsInFlight,
 		path:                   opts.Path,
 		port:                   opts.Port,
+		ready:                  make(chan struct{}),
+		scraped:                make(chan struct{}),
 		tlsCertFile:            opts.TLS.CertFile,
 		tlsKeyFile:             opts.TLS.KeyFile,
 		tlsInsecureSkipVerify:  opts.TLS.InsecureSkipVerify,
 		tlsMinVersion:          opts.TLS.MinVersion,
+		tlsClientCAFile:        opts.TLS.ClientCAFile,
+		tlsClientAuth:          opts.TLS.ClientAuth,
+		tlsReloadInterval:      opts.TLS.ReloadInterval,
+		tlsReloadOnSIGHUP:      opts.TLS.ReloadOnSIGHUP,
 		terminationGracePeriod: opts.TerminationGracePeriod,
-		stopChan:               make(chan struct{}, 1),
 	}
 }
 
 // Start creates a new http server which listens on the TCP address addr
-// and port.
+// and port.  It blocks until the server is shut down, either by cancelling the
+// context or by calling Shutdown or Stop, and returns nil after a graceful
+// shutdown.  Errors that prevent the server from starting, such as an address
+// that is already in use or an invalid certificate, are returned.
 func (s *Server) Start(ctx context.Context, reg *prometheus.Registry) error {
+	return s.start(ctx, reg, nil, reg, nil)
+}
+
+// start serves the gatherer on the metrics path.  The scrape metrics are
+// registered with the registerer and the store, if not nil, provides the
+// native OpenMetrics types.
+func (s *Server) start(
+	ctx context.Context,
+	gatherer prometheus.Gatherer,
+	store *Store,
+	registerer prometheus.Registerer,
+	health *healthChecks,
+) error {
+	// The context is cancelled on return so the certificate reloader stops
+	// when the server is shut down with Shutdown.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	auth, err := newAuthenticator(s.auth)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := s.serverTLSConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.validateEndpoints(); err != nil {
+		return err
+	}
+
+	if err := s.debug.validate(); err != nil {
+		return err
+	}
+
+	if s.gzipLevel < gzip.HuffmanOnly || s.gzipLevel > gzip.BestCompression {
+		return fmt.Errorf("invalid gzip compression level: %d", s.gzipLevel)
+	}
+
+	scrape := newScrapeMetrics(registerer)
 	mux := http.NewServeMux()
-	mux.Handle(s.path, promhttp.HandlerFor(reg, promhttp.HandlerOpts{
-		Timeout: DefaultTimeout,
-	}))
+	mux.Handle(s.path, auth.wrap(s.track(newHandler(gatherer, store, s.handlerOpts(), scrape.observer(s.path)))))
+	for path, metrics := range s.endpoints {
+		mux.Handle(path, auth.wrap(s.track(newHandler(metrics.registry, metrics.store, s.handlerOpts(), scrape.observer(path)))))
+	}
+	if s.enableHealthChecks && health != nil {
+		health.mount(mux)
+	}
 
-	server := &http.Server{
-		Addr:        fmt.Sprintf("%s:%d", s.bindAddr, s.port),
-		ReadTimeout: DefaultTimeout,
-		Handler:     mux,
-		BaseContext: func(_ net.Listener) context.Context {
-			return ctx
-		},
+	listener := s.listener
+	if listener == nil {
+		listener, err = listen(s.bindAddr, s.port)
+		if err != nil {
+			return err
+		}
+	}
+
+	server := s.httpServer(ctx, mux, tlsConfig)
+	servers := []*http.Server{server}
+	var debugAddr net.Addr
+
+	if s.debug.enabled() && s.debug.separate() {
+		debugListener, err := listen(s.debug.BindAddr, s.debug.Port)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+
+		debugMux := http.NewServeMux()
+		mountDebug(debugMux, s.debug, auth)
+		debugServer := s.httpServer(ctx, debugMux, tlsConfig)
+		servers = append(servers, debugServer)
+		debugAddr = debugListener.Addr()
+
+		go func() {
+			s.logger.Info("starting debug endpoint", "addr", debugListener.Addr().String())
+			if err := serve(debugServer, debugListener); !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error(err, "debug endpoint error")
+			}
+		}()
+	} else {
+		mountDebug(mux, s.debug, auth)
+	}
+
+	if !s.register(listener.Addr(), debugAddr, servers) {
+		// Shutdown was called before the server started.
+		for _, srv := range servers {
+			_ = srv.Close()
+		}
+		listener.Close()
+		return nil
 	}
 
 	go func() {
-		<-ctx.Done()
-		time.Sleep(s.terminationGracePeriod)
-		if err := shutdown(server); err != nil {
-			s.logger.Error(err, "shutting down prometheus collector endpoint")
+		select {
+		case <-ctx.Done():
+			toCtx, cancel := context.WithTimeout(context.Background(), s.terminationGracePeriod+DefaultTimeout)
+			defer cancel()
+			_ = s.Shutdown(toCtx)
+		case <-s.done:
 		}
 	}()
 
-	if s.tlsCertFile != "" && s.tlsKeyFile != "" {
-		server.TLSConfig = &tls.Config{
-			MinVersion:         s.tlsMinVersion,
-			InsecureSkipVerify: s.tlsInsecureSkipVerify, // nolint:gosec
+	s.logger.Info("starting prometheus collector endpoint", "tls", tlsConfig != nil, "listen", listener.Addr().String(), "config", s.config())
+	err = serve(server, listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		<-s.done
+		return s.shutdownErr
+	}
+
+	for _, srv := range servers {
+		_ = srv.Close()
+	}
+	return err
+}
+
+// validateEndpoints checks that the additional endpoints don't conflict with
+// the paths that are already served.
+func (s *Server) validateEndpoints() error {
+	reserved := []string{s.path}
+	if s.enableHealthChecks {
+		health := []string{HealthzPath, ReadyzPath, LivezPath}
+		if inUse(health, s.path) {
+			return fmt.Errorf("%w: %s is already in use", ErrInvalidEndpoint, s.path)
 		}
+		reserved = append(reserved, health...)
+	}
 
-		s.logger.Info("starting prometheus collector endpoint", "tls", true, "config", s.config())
-		return server.ListenAndServeTLS(s.tlsCertFile, s.tlsKeyFile)
+	if s.debug.enabled() && !s.debug.separate() {
+		for _, path := range sortedKeys(s.debug.Handlers) {
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("%w: %s must start with /", ErrInvalidEndpoint, path)
+			}
+			if inUse(reserved, path) {
+				return fmt.Errorf("%w: %s is already in use", ErrInvalidEndpoint, path)
+			}
+		}
+		reserved = append(reserved, sortedKeys(s.debug.Handlers)...)
+	}
+
+	for path, metrics := range s.endpoints {
+		if metrics == nil {
+			return fmt.Errorf("%w: no metrics for endpoint %s", ErrInvalidEndpoint, path)
+		}
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%w: %s must start with /", ErrInvalidEndpoint, path)
+		}
+		if inUse(reserved, path) {
+			return fmt.Errorf("%w: %s is already in use", ErrInvalidEndpoint, path)
+		}
 	}
 
-	s.logger.Info("starting prometheus collector endpoint", "tls", false, "config", s.config())
-	return server.ListenAndServe()
+	return nil
 }
 
-// Stop closes the stop channel which initiates the shutdown of the HTTP server.
-func (s *Server) Stop() {
-	s.logger.Info("shutting down prometheus collector endpoint", "gracePeriod", s.terminationGracePeriod)
-	s.stopOnce.Do(func() {
-		time.Sleep(s.terminationGracePeriod)
-		close(s.stopChan)
+// inUse returns true if the path is one of the reserved paths, or is below a
+// reserved path that ends with a slash and matches all the paths below it.
+func inUse(reserved []string, path string) bool {
+	for _, r := range reserved {
+		if r == path || (strings.HasSuffix(r, "/") && strings.HasPrefix(path, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// register records the listening addresses and the http servers and signals
+// that the server is ready.  It returns false if the server has already been
+// shut down.
+func (s *Server) register(addr net.Addr, debugAddr net.Addr, servers []*http.Server) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopped {
+		return false
+	}
+
+	s.addr = addr
+	s.debugAddr = debugAddr
+	s.servers = servers
+	s.readyOnce.Do(func() {
+		close(s.ready)
+		if s.onReady != nil {
+			s.onReady()
+		}
 	})
+
+	return true
+}
+
+// track signals that a scrape has completed once the server is draining so
+// the termination grace period can end early.
+func (s *Server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if s.draining.Load() {
+			s.scrapedOnce.Do(func() {
+				close(s.scraped)
+			})
+		}
+	})
+}
+
+// Ready returns a channel that is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Shutdown gracefully shuts down the server.  The server waits up to the
+// TerminationGracePeriod for a final scrape, ending the grace period as soon
+// as a scrape completes, then stops accepting connections and waits for the
+// active requests to finish.  If the context is done first, the context error
+// is returned.  Calling Shutdown more than once returns the result of the
+// first call.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown(ctx)
+		close(s.done)
+	})
+
+	<-s.done
+	return s.shutdownErr
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	s.Lock()
+	s.stopped = true
+	servers := s.servers
+	s.Unlock()
+
+	if len(servers) == 0 {
+		return nil
+	}
+
+	s.logger.Info("shutting down prometheus collector endpoint", "gracePeriod", s.terminationGracePeriod)
+	s.draining.Store(true)
+
+	if s.terminationGracePeriod > 0 {
+		timer := time.NewTimer(s.terminationGracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-s.scraped:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			s.logger.Error(err, "shutting down prometheus collector endpoint")
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Addr returns the address the server is listening on, or nil if the server
+// has not started listening.  It is useful when EphemeralPort is set.
+func (s *Server) Addr() net.Addr {
+	s.RLock()
+	defer s.RUnlock()
+	return s.addr
+}
+
+// DebugAddr returns the address of the separate debug listener, or nil if the
+// debug endpoints are not served on their own listener or the server has not
+// started listening.  It is useful when DebugOpts.EphemeralPort is set.
+func (s *Server) DebugAddr() net.Addr {
+	s.RLock()
+	defer s.RUnlock()
+	return s.debugAddr
+}
+
+func (s *Server) httpServer(ctx context.Context, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		ReadTimeout: DefaultTimeout,
+		Handler:     handler,
+		TLSConfig:   tlsConfig,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+}
+
+// serverTLSConfig returns the TLS configuration for the server, or nil if TLS
+// is not enabled.  The certificate is served by a reloader which is watched
+// until the context is done.
+func (s *Server) serverTLSConfig(ctx context.Context) (*tls.Config, error) {
+	if s.tlsCertFile == "" || s.tlsKeyFile == "" {
+		return nil, nil
+	}
+
+	config, err := s.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(s.tlsCertFile, s.tlsKeyFile, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	config.GetCertificate = reloader.GetCertificate
+	go reloader.watch(ctx, s.tlsReloadInterval, s.tlsReloadOnSIGHUP)
+
+	return config, nil
+}
+
+// listen creates the listener for the address.  Addresses prefixed with
+// unix:// listen on a Unix domain socket, otherwise a TCP listener is created
+// on the address and port.
+func listen(addr string, port int) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		// Remove a socket left behind by a previous process that did not
+		// shut down cleanly.  A socket that still accepts connections is left
+		// in place and the listen fails.
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+			} else {
+				_ = os.Remove(path)
+			}
+		}
+		return net.Listen("unix", path)
+	}
+
+	return net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+}
+
+func serve(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		// The certificate is served by the reloader through GetCertificate.
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
+
+// Stop gracefully shuts down the server.  It is equivalent to calling
+// Shutdown with a context that expires after the TerminationGracePeriod and
+// the default timeout.
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.terminationGracePeriod+DefaultTimeout)
+	defer cancel()
+	_ = s.Shutdown(ctx)
+}
+
+// serverRef holds the server started by a Metrics.  It is shared between a
+// Metrics and all of the Metrics derived from it so the server can be
+// reached from any of them.
+type serverRef struct {
+	server    *Server
+	ready     chan struct{}
+	readyOnce sync.Once
+	sync.RWMutex
+}
+
+func newServerRef() *serverRef {
+	return &serverRef{
+		ready: make(chan struct{}),
+	}
+}
+
+func (r *serverRef) get() *Server {
+	r.RLock()
+	defer r.RUnlock()
+	return r.server
+}
+
+func (r *serverRef) set(server *Server) {
+	r.Lock()
+	defer r.Unlock()
+	r.server = server
+	server.onReady = func() {
+		r.readyOnce.Do(func() {
+			close(r.ready)
+		})
+	}
 }
 
 // WithLogger defines the logger that will be used with the server.
@@ -129,26 +585,79 @@ func (s *Server) WithLogger(logger Logger) *Server {
 	return s
 }
 
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: s.tlsMinVersion,
+	}
+
+	if s.tlsClientCAFile != "" {
+		pem, err := os.ReadFile(s.tlsClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", s.tlsClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = s.tlsClientAuth
+		if config.ClientAuth == tls.NoClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config, nil
+}
+
+func (s *Server) handlerOpts() HandlerOpts {
+	return HandlerOpts{
+		EnableOpenMetrics:   s.enableOpenMetrics,
+		ExcludeFamilies:     s.excludeFamilies,
+		MaxRequestsInFlight: s.maxRequestsInFlight,
+		GzipLevel:           s.gzipLevel,
+		ZstdLevel:           s.zstdLevel,
+		GatherCacheTTL:      s.gatherCacheTTL,
+	}
+}
+
 func (s *Server) config() map[string]any {
 	return map[string]any{
 		"addr":                          s.bindAddr,
 		"path":                          s.path,
+		"openMetrics":                   s.enableOpenMetrics,
+		"healthChecks":                  s.enableHealthChecks,
+		"excludeFamilies":               s.excludeFamilies,
+		"endpoints":                     sortedKeys(s.endpoints),
+		"maxRequestsInFlight":           s.maxRequestsInFlight,
+		"gzipLevel":                     s.gzipLevel,
+		"zstdLevel":                     s.zstdLevel,
+		"gatherCacheTTL":                s.gatherCacheTTL.String(),
 		"port":                          s.port,
+		"listener":                      s.listener != nil,
 		"terminationGracePeriodSeconds": s.terminationGracePeriod / time.Second,
 		"tls": map[string]any{
 			"certFile":           s.tlsCertFile,
 			"keyFile":            s.tlsKeyFile,
 			"insecureSkipVerify": s.tlsInsecureSkipVerify,
+			"clientCAFile":       s.tlsClientCAFile,
+			"clientAuth":         s.tlsClientAuth.String(),
+			"reloadInterval":     s.tlsReloadInterval.String(),
+			"reloadOnSIGHUP":     s.tlsReloadOnSIGHUP,
+		},
+		"auth": map[string]any{
+			"basic":  s.auth != nil && len(s.auth.BasicAuthUsers) > 0,
+			"bearer": s.auth != nil && s.auth.BearerTokenFile != "",
+		},
+		"debug": map[string]any{
+			"paths": sortedKeys(s.debug.Handlers),
+			"addr":  s.debug.BindAddr,
+			"port":  s.debug.Port,
 		},
 	}
 }
 
-func shutdown(server *http.Server) error {
-	toCtx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
-	return server.Shutdown(toCtx)
-}
-
 func defaultedServer(opts ServerOpts) ServerOpts {
 	if opts.BindAddr == "" {
 		opts.BindAddr = "0.0.0.0"
@@ -158,11 +667,14 @@ func defaultedServer(opts ServerOpts) ServerOpts {
 		opts.Path = "/metrics"
 	}
 
-	if opts.Port == 0 {
+	if opts.EphemeralPort {
+		opts.Port = 0
+	} else if opts.Port == 0 {
 		opts.Port = 9090
 	}
 
 	opts.TLS = defaultedTLS(opts.TLS)
+	opts.Debug = defaultedDebug(opts.Debug, opts.BindAddr)
 
 	return opts
 }
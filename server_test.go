@@ -0,0 +1,505 @@
+package strata
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCerts struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	caPool   *x509.CertPool
+	client   tls.Certificate
+}
+
+// generateTestCerts creates a CA along with a server and client certificate
+// signed by the CA in dir.
+func generateTestCerts(t *testing.T, dir string) testCerts {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "strata-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	certs := testCerts{
+		caFile:   filepath.Join(dir, "ca.crt"),
+		certFile: filepath.Join(dir, "server.crt"),
+		keyFile:  filepath.Join(dir, "server.key"),
+		caPool:   x509.NewCertPool(),
+	}
+	certs.caPool.AddCert(ca)
+
+	require.NoError(t, os.WriteFile(certs.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+	serverCert, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.WriteFile(certs.certFile, serverCert, 0o600))
+	require.NoError(t, os.WriteFile(certs.keyFile, serverKey, 0o600))
+
+	clientCert, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+	certs.client, err = tls.X509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+
+	return certs
+}
+
+// startTestServer starts the metrics server in the background and waits until
+// it is accepting connections.
+func startTestServer(t *testing.T, m *Metrics, opts ServerOpts) (context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.Start(ctx, opts)
+	}()
+
+	select {
+	case <-m.Ready():
+	case err := <-errCh:
+		cancel()
+		require.FailNow(t, "server failed to start", err)
+	case <-time.After(5 * time.Second):
+		cancel()
+		require.FailNow(t, "timed out waiting for the server to start")
+	}
+
+	return cancel, errCh
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	certs := generateTestCerts(t, t.TempDir())
+
+	m := New(MetricsOpts{})
+	cancel, _ := startTestServer(t, m, ServerOpts{
+		BindAddr:      "127.0.0.1",
+		EphemeralPort: true,
+		TLS: &TLSOpts{
+			CertFile:     certs.certFile,
+			KeyFile:      certs.keyFile,
+			ClientCAFile: certs.caFile,
+		},
+	})
+	defer cancel()
+
+	url := fmt.Sprintf("https://%s/metrics", m.Addr())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      certs.caPool,
+		Certificates: []tls.Certificate{certs.client},
+		MinVersion:   tls.VersionTLS13,
+	}}}
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    certs.caPool,
+		MinVersion: tls.VersionTLS13,
+	}}}
+	resp, err = anonymous.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestServerEphemeralPort(t *testing.T) {
+	m := New(MetricsOpts{})
+	assert.Nil(t, m.Addr())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = m.Start(ctx, ServerOpts{BindAddr: "127.0.0.1", EphemeralPort: true})
+	}()
+
+	require.Eventually(t, func() bool {
+		return m.Addr() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The address is shared with metrics derived from the one that was started.
+	addr := m.WithPrefix("child").Addr()
+	require.NotNil(t, addr)
+	assert.NotZero(t, addr.(*net.TCPAddr).Port)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServerListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	m := New(MetricsOpts{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = m.Start(ctx, ServerOpts{Listener: listener})
+	}()
+
+	require.Eventually(t, func() bool {
+		return m.Addr() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, listener.Addr().String(), m.Addr().String())
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", listener.Addr()))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	// Socket paths are limited in length so the default test directory may be
+	// too long.
+	dir, err := os.MkdirTemp("", "strata")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.sock")
+
+	m := New(MetricsOpts{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = m.Start(ctx, ServerOpts{BindAddr: "unix://" + path})
+	}()
+
+	require.Eventually(t, func() bool {
+		return m.Addr() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "unix", m.Addr().Network())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://strata/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "strata")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stale.sock")
+
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listen("unix://"+path, 0)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, err = listen("unix://"+path, 0)
+	assert.Error(t, err)
+}
+
+func scrape(t *testing.T, addr net.Addr) {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func waitStopped(t *testing.T, errCh <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for the server to stop")
+		return nil
+	}
+}
+
+func TestServerShutdownOnContextCancel(t *testing.T) {
+	m := New(MetricsOpts{})
+	cancel, errCh := startTestServer(t, m, ServerOpts{BindAddr: "127.0.0.1", EphemeralPort: true})
+
+	scrape(t, m.Addr())
+	cancel()
+	assert.NoError(t, waitStopped(t, errCh))
+}
+
+func TestServerStop(t *testing.T) {
+	m := New(MetricsOpts{})
+	cancel, errCh := startTestServer(t, m, ServerOpts{BindAddr: "127.0.0.1", EphemeralPort: true})
+	defer cancel()
+
+	m.WithPrefix("child").Stop()
+	assert.NoError(t, waitStopped(t, errCh))
+
+	_, err := net.Dial("tcp", m.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestServerShutdownWaitsForGracePeriod(t *testing.T) {
+	m := New(MetricsOpts{})
+	cancel, errCh := startTestServer(t, m, ServerOpts{
+		BindAddr:               "127.0.0.1",
+		EphemeralPort:          true,
+		TerminationGracePeriod: 200 * time.Millisecond,
+	})
+	defer cancel()
+
+	start := time.Now()
+	require.NoError(t, m.Shutdown(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.NoError(t, waitStopped(t, errCh))
+}
+
+func TestServerShutdownEndsGracePeriodAfterScrape(t *testing.T) {
+	m := New(MetricsOpts{})
+	cancel, errCh := startTestServer(t, m, ServerOpts{
+		BindAddr:               "127.0.0.1",
+		EphemeralPort:          true,
+		TerminationGracePeriod: time.Minute,
+	})
+	defer cancel()
+
+	addr := m.Addr()
+	shutdown := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		shutdown <- m.Shutdown(context.Background())
+	}()
+
+	// The server keeps serving during the grace period.
+	require.Eventually(t, func() bool {
+		return m.server.get().draining.Load()
+	}, 5*time.Second, time.Millisecond)
+	scrape(t, addr)
+
+	select {
+	case err := <-shutdown:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "grace period did not end after the final scrape")
+	}
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.NoError(t, waitStopped(t, errCh))
+}
+
+func TestServerShutdownContextExpired(t *testing.T) {
+	m := New(MetricsOpts{})
+	cancel, errCh := startTestServer(t, m, ServerOpts{
+		BindAddr:               "127.0.0.1",
+		EphemeralPort:          true,
+		TerminationGracePeriod: time.Minute,
+	})
+	defer cancel()
+
+	// Hold a request open so the server can't finish shutting down.
+	block := make(chan struct{})
+	defer close(block)
+	m.GaugeFunc("blocking", func() float64 {
+		<-block
+		return 0
+	})
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/metrics", m.Addr()))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelShutdown()
+	assert.ErrorIs(t, m.Shutdown(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, waitStopped(t, errCh), context.DeadlineExceeded)
+}
+
+func TestServerShutdownBeforeStart(t *testing.T) {
+	s := newServer(ServerOpts{BindAddr: "127.0.0.1", EphemeralPort: true})
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start(context.Background(), New(MetricsOpts{}).registry)
+	}()
+	assert.NoError(t, waitStopped(t, errCh))
+	assert.Nil(t, s.Addr())
+}
+
+func TestServerStartPortInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	m := New(MetricsOpts{})
+	err = m.Start(context.Background(), ServerOpts{
+		BindAddr: "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+	})
+	assert.Error(t, err)
+}
+
+func TestServerStartInvalidCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, []byte("invalid"), 0o600))
+
+	m := New(MetricsOpts{})
+	err := m.Start(context.Background(), ServerOpts{
+		BindAddr:      "127.0.0.1",
+		EphemeralPort: true,
+		TLS:           &TLSOpts{CertFile: certFile, KeyFile: keyFile},
+	})
+	assert.Error(t, err)
+
+	select {
+	case <-m.Ready():
+		assert.Fail(t, "ready should not be signalled when the server fails to start")
+	default:
+	}
+}
+
+func TestServerEndpoints(t *testing.T) {
+	m := New(MetricsOpts{})
+	debug := m.WithRegistry(nil)
+	m.CounterInc("core_total")
+	debug.CounterInc("debug_total")
+
+	cancel, _ := startTestServer(t, m, ServerOpts{
+		BindAddr:      "127.0.0.1",
+		EphemeralPort: true,
+		Endpoints:     map[string]*Metrics{"/metrics/debug": debug},
+	})
+	defer cancel()
+
+	get := func(path string) string {
+		resp, err := http.Get(fmt.Sprintf("http://%s%s", m.Addr(), path))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return string(body)
+	}
+
+	body := get("/metrics")
+	assert.Contains(t, body, "core_total 1")
+	assert.NotContains(t, body, "debug_total")
+
+	body = get("/metrics/debug")
+	assert.Contains(t, body, "debug_total 1")
+	assert.NotContains(t, body, "core_total")
+}
+
+func TestServerEndpointsInvalid(t *testing.T) {
+	m := New(MetricsOpts{})
+	for _, endpoints := range []map[string]*Metrics{
+		{"/metrics": m.WithRegistry(nil)},
+		{"/healthz": m.WithRegistry(nil)},
+		{"metrics/debug": m.WithRegistry(nil)},
+		{"/metrics/debug": nil},
+	} {
+		err := m.Start(context.Background(), ServerOpts{
+			BindAddr:           "127.0.0.1",
+			EphemeralPort:      true,
+			EnableHealthChecks: true,
+			Endpoints:          endpoints,
+		})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+	}
+}
+
+func TestServerPathHealthChecks(t *testing.T) {
+	m := New(MetricsOpts{})
+	for _, path := range []string{HealthzPath, ReadyzPath, LivezPath} {
+		err := m.Start(context.Background(), ServerOpts{
+			BindAddr:           "127.0.0.1",
+			EphemeralPort:      true,
+			Path:               path,
+			EnableHealthChecks: true,
+		})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+	}
+}
+
+func TestServerEndpointsDebugPaths(t *testing.T) {
+	m := New(MetricsOpts{})
+	for _, endpoints := range []map[string]*Metrics{
+		{"/debug/vars": m.WithRegistry(nil)},
+		{"/debug/pprof/": m.WithRegistry(nil)},
+		{"/debug/pprof/heap": m.WithRegistry(nil)},
+	} {
+		err := m.Start(context.Background(), ServerOpts{
+			BindAddr:      "127.0.0.1",
+			EphemeralPort: true,
+			Debug:         &DebugOpts{Handlers: debugHandlers()},
+			Endpoints:     endpoints,
+		})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+	}
+
+	// The debug handlers can't shadow the metrics endpoint either.
+	err := m.Start(context.Background(), ServerOpts{
+		BindAddr:      "127.0.0.1",
+		EphemeralPort: true,
+		Debug:         &DebugOpts{Handlers: map[string]http.Handler{"/metrics": http.NotFoundHandler()}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidEndpoint)
+}
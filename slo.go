@@ -0,0 +1,273 @@
+package strata
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSLOTarget is the target used when the target of an SLO is not
+	// set.
+	DefaultSLOTarget = 0.99
+	// DefaultSLOWindow is the window used to calculate the remaining error
+	// budget of an SLO when the window is not set.
+	DefaultSLOWindow = 28 * 24 * time.Hour
+
+	// sloSlots is the number of intervals the window of an SLO is divided
+	// into.  Events expire from the window one interval at a time.
+	sloSlots = 100
+)
+
+// SLOOpts defines the options of a service level objective.
+type SLOOpts struct {
+	// Target is the objective for the ratio of good events, e.g. 0.999.  It
+	// must be between 0 and 1.  By default DefaultSLOTarget is used.
+	Target float64
+	// Window is the sliding window the remaining error budget is calculated
+	// over.  By default DefaultSLOWindow is used.
+	Window time.Duration
+	// LatencyThreshold is the maximum duration of a good event.  Events that
+	// take longer are counted as bad even if they succeeded.  A bucket is
+	// added at the threshold to the latency histogram.  By default the
+	// duration is not considered.
+	LatencyThreshold time.Duration
+}
+
+// SLO tracks a service level objective.  It is created with Metrics.SLO and
+// maintains the following metrics:
+//
+//	<name>_requests_total          counter of all events
+//	<name>_good_requests_total     counter of the good events
+//	<name>_duration_seconds        histogram of the event durations
+//	<name>_error_budget_remaining  ratio of the error budget that remains in
+//	                               the window, negative once it is exhausted
+type SLO struct {
+	metrics   *Metrics
+	name      string
+	target    float64
+	threshold time.Duration
+	window    *sloWindow
+	now       func() time.Time
+}
+
+// SLO returns the tracker for the service level objective with the name.  The
+// metrics are registered through the store using the prefix of the metrics
+// and without variable labels.  Calling SLO again with the same name returns
+// the existing tracker and ignores the options.  An error wrapping
+// ErrInvalidSLO is returned if the target is not between 0 and 1, the window
+// or latency threshold is negative, or the schema declares buckets for the
+// latency histogram without a bucket at the threshold.  Example:
+//
+//	slo, err := m.SLO("checkout", strata.SLOOpts{
+//		Target:           0.999,
+//		Window:           28 * 24 * time.Hour,
+//		LatencyThreshold: 300 * time.Millisecond,
+//	})
+//	start := time.Now()
+//	err = checkout()
+//	slo.Record(err == nil, time.Since(start))
+func (m *Metrics) SLO(name string, opts SLOOpts) (*SLO, error) {
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidSLO, name, err)
+	}
+
+	opts = defaultedSLO(opts)
+	fqName := prefixedName(m.prefix, name, m.separator)
+
+	// The declared buckets replace the buckets of the histogram, so they must
+	// already include the threshold.
+	threshold := opts.LatencyThreshold.Seconds()
+	if d := m.store.schema.lookup(prefixedName(m.prefix, name+"_duration_seconds", m.separator)); d != nil && len(d.Buckets) > 0 {
+		if threshold > 0 && !slices.Contains(d.Buckets, threshold) {
+			return nil, fmt.Errorf("%w: %s: the declared buckets of %s don't include the latency threshold %v", ErrInvalidSLO, name, d.Name, threshold)
+		}
+	}
+
+	m.slos.Lock()
+	defer m.slos.Unlock()
+
+	if slo, ok := m.slos.slos[fqName]; ok {
+		return slo, nil
+	}
+
+	metrics := m.WithLabels()
+	metrics.histogramBuckets = withBucket(m.histogramBuckets, threshold)
+
+	slo := &SLO{
+		metrics:   metrics,
+		name:      name,
+		target:    opts.Target,
+		threshold: opts.LatencyThreshold,
+		window:    newSLOWindow(opts.Window),
+		now:       time.Now,
+	}
+	m.slos.slos[fqName] = slo
+
+	if m.recorder == nil {
+		slo.register()
+	}
+
+	return slo, nil
+}
+
+// register creates the metrics of the SLO so they are exposed before the
+// first event.
+func (s *SLO) register() {
+	m := s.metrics
+	counters := []string{s.name + "_requests_total", s.name + "_good_requests_total"}
+	for _, name := range counters {
+		vec, err := m.store.getCounter(m.registerer, prefixedName(m.prefix, name, m.separator))
+		if err != nil {
+			m.emitError(err, name, "slo")
+			continue
+		}
+		vec.vec.WithLabelValues()
+	}
+
+	name := s.name + "_duration_seconds"
+	vec, err := m.store.getHistogram(m.registerer, prefixedName(m.prefix, name, m.separator), m.histogramBuckets)
+	if err != nil {
+		m.emitError(err, name, "slo")
+	} else {
+		vec.vec.WithLabelValues()
+	}
+
+	m.GaugeFunc(s.name+"_error_budget_remaining", s.Remaining)
+}
+
+// Record records an event.  The event is good if ok is true and the duration
+// doesn't exceed the latency threshold.
+func (s *SLO) Record(ok bool, d time.Duration) {
+	good := ok && (s.threshold == 0 || d <= s.threshold)
+
+	s.metrics.CounterInc(s.name + "_requests_total")
+	if good {
+		s.metrics.CounterInc(s.name + "_good_requests_total")
+	}
+	s.metrics.HistogramObserve(s.name+"_duration_seconds", d.Seconds())
+
+	s.window.record(s.now(), good)
+}
+
+// Remaining returns the ratio of the error budget that remains in the
+// window.  It is 1 if there were no bad events and becomes negative once the
+// budget is exhausted.
+func (s *SLO) Remaining() float64 {
+	total, good := s.window.sum(s.now())
+	if total == 0 {
+		return 1
+	}
+
+	bad := float64(total-good) / float64(total)
+	return 1 - bad/(1-s.target)
+}
+
+// sloRegistry holds the SLOs created by the metrics, keyed by the full name.
+// It is shared by the metrics derived with WithPrefix and WithLabels.
+type sloRegistry struct {
+	slos map[string]*SLO
+	sync.Mutex
+}
+
+func newSLORegistry() *sloRegistry {
+	return &sloRegistry{slos: make(map[string]*SLO)}
+}
+
+// sloWindow counts the events in a sliding window that is divided into
+// sloSlots intervals.
+type sloWindow struct {
+	interval time.Duration
+	slots    [sloSlots]sloSlot
+	sync.Mutex
+}
+
+type sloSlot struct {
+	index int64
+	total uint64
+	good  uint64
+}
+
+func newSLOWindow(window time.Duration) *sloWindow {
+	interval := window / sloSlots
+	if interval <= 0 {
+		interval = 1
+	}
+	return &sloWindow{interval: interval}
+}
+
+func (w *sloWindow) record(now time.Time, good bool) {
+	index := now.UnixNano() / int64(w.interval)
+
+	w.Lock()
+	defer w.Unlock()
+
+	slot := &w.slots[index%sloSlots]
+	if slot.index != index {
+		*slot = sloSlot{index: index}
+	}
+
+	slot.total++
+	if good {
+		slot.good++
+	}
+}
+
+// sum returns the number of events and good events in the window ending at
+// now.
+func (w *sloWindow) sum(now time.Time) (uint64, uint64) {
+	index := now.UnixNano() / int64(w.interval)
+
+	w.Lock()
+	defer w.Unlock()
+
+	var total, good uint64
+	for _, slot := range w.slots {
+		if slot.index > index-sloSlots && slot.index <= index {
+			total += slot.total
+			good += slot.good
+		}
+	}
+	return total, good
+}
+
+// withBucket returns the buckets with an additional bucket at the upper
+// bound, unless it is already present or not positive.
+func withBucket(buckets []float64, bound float64) []float64 {
+	if bound <= 0 || slices.Contains(buckets, bound) {
+		return buckets
+	}
+
+	b := append(slices.Clone(buckets), bound)
+	slices.Sort(b)
+	return b
+}
+
+func (o *SLOOpts) validate() error {
+	if o.Target < 0 || o.Target >= 1 {
+		return fmt.Errorf("the target %v is not between 0 and 1", o.Target)
+	}
+
+	if o.Window < 0 {
+		return fmt.Errorf("the window %s is negative", o.Window)
+	}
+
+	if o.LatencyThreshold < 0 {
+		return fmt.Errorf("the latency threshold %s is negative", o.LatencyThreshold)
+	}
+
+	return nil
+}
+
+func defaultedSLO(opts SLOOpts) SLOOpts {
+	if opts.Target == 0 {
+		opts.Target = DefaultSLOTarget
+	}
+
+	if opts.Window == 0 {
+		opts.Window = DefaultSLOWindow
+	}
+
+	return opts
+}
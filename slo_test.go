@@ -0,0 +1,156 @@
+package strata
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLO(t *testing.T) {
+	m := New(MetricsOpts{
+		Registry:         prometheus.NewRegistry(),
+		PanicOnError:     true,
+		HistogramBuckets: []float64{0.1, 0.5, 1},
+	}).WithPrefix("app")
+
+	slo, err := m.SLO("checkout", SLOOpts{
+		Target:           0.75,
+		Window:           time.Hour,
+		LatencyThreshold: 250 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	slo.now = func() time.Time { return now }
+
+	// The metrics are exposed before the first event.
+	assert.Equal(t, 1, testutil.CollectAndCount(m.registry, "app_checkout_requests_total"))
+	assert.InDelta(t, 1.0, slo.Remaining(), 1e-9)
+
+	for i := 0; i < 14; i++ {
+		slo.Record(true, 0)
+	}
+	for i := 0; i < 4; i++ {
+		slo.Record(false, 0)
+	}
+	// Slow events are bad even if they succeed.
+	slo.Record(true, time.Second)
+	slo.Record(true, 250*time.Millisecond)
+
+	// 5 of 20 events are bad, which uses the whole 25% budget.
+	assert.InDelta(t, 0.0, slo.Remaining(), 1e-9)
+
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(`
+# HELP app_checkout_requests_total created automagically by strata
+# TYPE app_checkout_requests_total counter
+app_checkout_requests_total 20
+# HELP app_checkout_good_requests_total created automagically by strata
+# TYPE app_checkout_good_requests_total counter
+app_checkout_good_requests_total 15
+# HELP app_checkout_error_budget_remaining created automagically by strata
+# TYPE app_checkout_error_budget_remaining gauge
+app_checkout_error_budget_remaining 0
+# HELP app_checkout_duration_seconds created automagically by strata
+# TYPE app_checkout_duration_seconds histogram
+app_checkout_duration_seconds_bucket{le="0.1"} 18
+app_checkout_duration_seconds_bucket{le="0.25"} 19
+app_checkout_duration_seconds_bucket{le="0.5"} 19
+app_checkout_duration_seconds_bucket{le="1"} 20
+app_checkout_duration_seconds_bucket{le="+Inf"} 20
+app_checkout_duration_seconds_sum 1.25
+app_checkout_duration_seconds_count 20
+`), "app_checkout_requests_total", "app_checkout_good_requests_total",
+		"app_checkout_error_budget_remaining", "app_checkout_duration_seconds"))
+
+	// More bad events overspend the budget.
+	for i := 0; i < 20; i++ {
+		slo.Record(false, 0)
+	}
+	assert.InDelta(t, -1.5, slo.Remaining(), 1e-9)
+
+	// The events expire from the sliding window.
+	now = now.Add(30 * time.Minute)
+	for i := 0; i < 10; i++ {
+		slo.Record(true, 0)
+	}
+	assert.InDelta(t, -1.0, slo.Remaining(), 1e-9)
+
+	now = now.Add(31 * time.Minute)
+	assert.InDelta(t, 1.0, slo.Remaining(), 1e-9)
+}
+
+func TestSLOExisting(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true})
+
+	slo, err := m.SLO("api", SLOOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSLOTarget, slo.target)
+
+	existing, err := m.WithLabels("ignored").SLO("api", SLOOpts{Target: 0.5})
+	require.NoError(t, err)
+	assert.Same(t, slo, existing)
+
+	other, err := m.WithPrefix("other").SLO("api", SLOOpts{})
+	require.NoError(t, err)
+	assert.NotSame(t, slo, other)
+}
+
+func TestSLOInvalid(t *testing.T) {
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), PanicOnError: true})
+
+	for name, opts := range map[string]SLOOpts{
+		"percent target":     {Target: 99},
+		"target of 1":        {Target: 1.0},
+		"negative target":    {Target: -0.5},
+		"negative window":    {Target: 0.99, Window: -time.Hour},
+		"negative threshold": {Target: 0.99, LatencyThreshold: -time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			slo, err := m.SLO("api", opts)
+			assert.Nil(t, slo)
+			assert.ErrorIs(t, err, ErrInvalidSLO)
+		})
+	}
+}
+
+func TestSLOSchemaBuckets(t *testing.T) {
+	schema, err := ParseSchema([]byte(`
+metrics:
+  - name: api_duration_seconds
+    type: histogram
+    buckets: [0.1, 1]
+`))
+	require.NoError(t, err)
+
+	m := New(MetricsOpts{Registry: prometheus.NewRegistry(), Schema: schema, PanicOnError: true})
+
+	_, err = m.SLO("api", SLOOpts{LatencyThreshold: 300 * time.Millisecond})
+	assert.ErrorIs(t, err, ErrInvalidSLO)
+
+	_, err = m.SLO("api", SLOOpts{LatencyThreshold: 100 * time.Millisecond})
+	assert.NoError(t, err)
+}
+
+func TestSLORecorder(t *testing.T) {
+	rec := NewRecorder()
+	m := New(MetricsOpts{Recorder: rec})
+
+	slo, err := m.SLO("api", SLOOpts{Target: 0.99})
+	require.NoError(t, err)
+	slo.Record(false, time.Millisecond)
+
+	require.Len(t, rec.Events(), 2)
+	assert.Equal(t, "api_requests_total", rec.Events()[0].Name)
+	assert.Equal(t, "api_duration_seconds", rec.Events()[1].Name)
+	assert.InDelta(t, -99.0, slo.Remaining(), 1e-9)
+}
+
+func TestWithBucket(t *testing.T) {
+	assert.Equal(t, []float64{0.1, 0.3, 0.5}, withBucket([]float64{0.1, 0.5}, 0.3))
+	assert.Equal(t, []float64{0.1, 0.5}, withBucket([]float64{0.1, 0.5}, 0.5))
+	assert.Equal(t, []float64{0.1, 0.5}, withBucket([]float64{0.1, 0.5}, 0))
+}
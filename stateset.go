@@ -0,0 +1,137 @@
+package strata
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StateSet represents a set of related boolean states such as the state of a
+// circuit breaker or leader election.  One series is exposed per state with
+// exactly one of them set to 1.  It is exposed as a gauge, or with the native
+// stateset type when OpenMetrics is negotiated.
+type StateSet struct {
+	name   string
+	label  string
+	states []string
+	vec    *prometheus.GaugeVec
+	// record is set when the StateSet was created by Metrics with a Recorder
+	// configured.
+	record func(state string, lv ...string)
+	// The mutex serializes the transitions so exactly one state is set.
+	sync.Mutex
+}
+
+// NewStateSet creates, registers, and returns a new StateSet.  The state label
+// shares the name of the metric as required by OpenMetrics.
+func NewStateSet(registerer prometheus.Registerer, name string, states []string, labels ...string) (*StateSet, error) {
+	set := newStateSet(name, states, labels...)
+	if err := Register(registerer, set.vec); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+func newStateSet(name string, states []string, labels ...string) *StateSet {
+	label := sanitizeLabelName(name)
+	set := &StateSet{
+		name:   name,
+		label:  label,
+		states: states,
+		vec: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name,
+			Help: DefaultHelpString,
+		}, append(append([]string{}, labels...), label)),
+	}
+
+	// Without variable labels the series can be initialized up front so all of
+	// the states are visible before the first transition.
+	if len(labels) == 0 {
+		for _, s := range states {
+			set.vec.WithLabelValues(s).Set(0)
+		}
+	}
+
+	return set
+}
+
+// SetState sets the state with the label values in the order that the labels
+// were defined in NewStateSet.  All of the other states are set to 0.  An error
+// is returned if the state is not one of the states that the StateSet was
+// created with, or if the number of label values doesn't match the labels.
+func (s *StateSet) SetState(state string, lv ...string) error {
+	if !s.valid(state) {
+		return fmt.Errorf("%w: %s", ErrUnknownState, state)
+	}
+
+	gauges := make([]prometheus.Gauge, len(s.states))
+	for i, st := range s.states {
+		gauge, err := s.vec.GetMetricWithLabelValues(append(append([]string{}, lv...), st)...)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidLabelValues, err)
+		}
+		gauges[i] = gauge
+	}
+
+	if s.record != nil {
+		s.record(state, lv...)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for i, st := range s.states {
+		var v float64
+		if st == state {
+			v = 1
+		}
+		gauges[i].Set(v)
+	}
+
+	return nil
+}
+
+// States returns the states of the StateSet.
+func (s *StateSet) States() []string {
+	return s.states
+}
+
+// Name returns the name of the StateSet.
+func (s *StateSet) Name() string {
+	return s.name
+}
+
+// Type returns the metric type.
+func (s *StateSet) Type() MetricType {
+	return StateSetType
+}
+
+// Vec returns the prometheus GaugeVec backing the StateSet.
+func (s *StateSet) Vec() prometheus.Collector {
+	return s.vec
+}
+
+func (s *StateSet) valid(state string) bool {
+	for _, st := range s.states {
+		if st == state {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeLabelName replaces any characters that are not allowed in label
+// names, such as the ':' separator, with underscores.
+func sanitizeLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9' && i > 0)) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+var _ MetricVec = &StateSet{}
@@ -0,0 +1,92 @@
+package strata
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateSet(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	set, err := NewStateSet(reg, "test_breaker", []string{"closed", "open"})
+	assert.NoError(t, err)
+	assert.Equal(t, StateSetType, set.Type())
+
+	expected := `# HELP test_breaker created automagically by strata
+# TYPE test_breaker gauge
+test_breaker{test_breaker="closed"} %s
+test_breaker{test_breaker="open"} %s
+`
+	assert.NoError(t, testutil.CollectAndCompare(set.Vec(), strings.NewReader(strings.Replace(strings.Replace(expected, "%s", "0", 1), "%s", "0", 1))))
+
+	assert.NoError(t, set.SetState("open"))
+	assert.NoError(t, testutil.CollectAndCompare(set.Vec(), strings.NewReader(strings.Replace(strings.Replace(expected, "%s", "0", 1), "%s", "1", 1))))
+
+	assert.NoError(t, set.SetState("closed"))
+	assert.NoError(t, testutil.CollectAndCompare(set.Vec(), strings.NewReader(strings.Replace(strings.Replace(expected, "%s", "1", 1), "%s", "0", 1))))
+
+	assert.ErrorIs(t, set.SetState("half_open"), ErrUnknownState)
+	assert.ErrorIs(t, set.SetState("open", "extra"), ErrInvalidLabelValues)
+}
+
+func TestStateSetConcurrent(t *testing.T) {
+	states := []string{"a", "b", "c", "d"}
+	set, err := NewStateSet(prometheus.NewRegistry(), "test_state", states)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(state string) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				assert.NoError(t, set.SetState(state))
+			}
+		}(states[i%len(states)])
+	}
+
+	// Exactly one state is set whenever the transitions are observed.
+	for i := 0; i < 1000; i++ {
+		set.Lock()
+		var sum float64
+		for _, st := range states {
+			sum += testutil.ToFloat64(set.vec.WithLabelValues(st))
+		}
+		set.Unlock()
+		if sum != 0 {
+			assert.Equal(t, 1.0, sum)
+		}
+	}
+	wg.Wait()
+}
+
+func TestMetricsStateSet(t *testing.T) {
+	m := testMetrics().WithLabels("region")
+	set := m.StateSet("leader", []string{"leader", "follower"})
+	assert.Same(t, set, m.StateSet("leader", []string{"leader", "follower"}))
+	assert.Equal(t, "strata_example_leader", set.Name())
+
+	assert.NoError(t, set.SetState("leader", "us-east-1"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(set.vec.WithLabelValues("us-east-1", "leader")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(set.vec.WithLabelValues("us-east-1", "follower")))
+}
+
+func TestMetricsStateSetRecorder(t *testing.T) {
+	rec := NewRecorder()
+	m := New(MetricsOpts{Recorder: rec, PanicOnError: true, Separator: ':'}).WithPrefix("strata")
+	set := m.StateSet("leader", []string{"leader", "follower"})
+
+	assert.NoError(t, set.SetState("follower"))
+	assert.Equal(t, []Event{{
+		Op:     "stateset_set",
+		Type:   StateSetType,
+		Name:   "strata:leader",
+		Labels: map[string]string{"strata_leader": "follower"},
+		Value:  1,
+	}}, rec.Events())
+}
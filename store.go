@@ -1,6 +1,7 @@
 package strata
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,6 +13,14 @@ type Store struct {
 	gauges     map[string]*GaugeVec
 	summaries  map[string]*SummaryVec
 	histograms map[string]*HistogramVec
+	funcs      map[string]MetricVec
+	infos      map[string]*InfoVec
+	statesets  map[string]*StateSet
+	schema     *schemaIndex
+	// declared holds the functions that remove the unused collectors created
+	// for the counters and gauges declared in the schema.  A declared metric
+	// that is registered with a callback replaces its collector.
+	declared map[string]func()
 	// TODO: part of the issue with the race condition was that we were
 	// setting the metric store value to nil and not revisiting.  This will
 	// pretty much address the double register race that caused the nil, but
@@ -26,6 +35,10 @@ func newStore() *Store {
 		gauges:     make(map[string]*GaugeVec),
 		summaries:  make(map[string]*SummaryVec),
 		histograms: make(map[string]*HistogramVec),
+		funcs:      make(map[string]MetricVec),
+		infos:      make(map[string]*InfoVec),
+		statesets:  make(map[string]*StateSet),
+		declared:   make(map[string]func()),
 	}
 }
 
@@ -33,11 +46,16 @@ func (s *Store) getCounter(reg prometheus.Registerer, name string, labels ...str
 	s.Lock()
 	defer s.Unlock()
 
+	if err := s.schema.check(name, CounterType, labels); err != nil {
+		return nil, err
+	}
+
 	if vec, ok := s.counters[name]; ok {
+		delete(s.declared, name)
 		return vec, nil
 	}
 
-	vec, err := NewCounterVec(reg, name, labels...)
+	vec, err := newCounterVec(reg, name, s.help(name), labels...)
 	s.counters[name] = vec
 	return vec, err
 }
@@ -46,11 +64,16 @@ func (s *Store) getGauge(reg prometheus.Registerer, name string, labels ...strin
 	s.Lock()
 	defer s.Unlock()
 
+	if err := s.schema.check(name, GaugeType, labels); err != nil {
+		return nil, err
+	}
+
 	if vec, ok := s.gauges[name]; ok {
+		delete(s.declared, name)
 		return vec, nil
 	}
 
-	vec, err := NewGaugeVec(reg, name, labels...)
+	vec, err := newGaugeVec(reg, name, s.help(name), labels...)
 	s.gauges[name] = vec
 	return vec, err
 }
@@ -59,11 +82,22 @@ func (s *Store) getSummary(reg prometheus.Registerer, name string, opts SummaryO
 	s.Lock()
 	defer s.Unlock()
 
+	if err := s.schema.check(name, SummaryType, labels); err != nil {
+		return nil, err
+	}
+
 	if vec, ok := s.summaries[name]; ok {
 		return vec, nil
 	}
 
-	vec, err := NewSummaryVec(reg, name, opts, labels...)
+	if m := s.schema.lookup(name); m != nil && len(m.Objectives) > 0 {
+		opts.Objectives = make(map[float64]float64, len(m.Objectives))
+		for _, o := range m.Objectives {
+			opts.Objectives[o.Quantile] = o.Error
+		}
+	}
+
+	vec, err := newSummaryVec(reg, name, s.help(name), opts, labels...)
 	s.summaries[name] = vec
 	return vec, err
 }
@@ -72,11 +106,192 @@ func (s *Store) getHistogram(reg prometheus.Registerer, name string, buckets []f
 	s.Lock()
 	defer s.Unlock()
 
+	if err := s.schema.check(name, HistogramType, labels); err != nil {
+		return nil, err
+	}
+
 	if vec, ok := s.histograms[name]; ok {
 		return vec, nil
 	}
 
-	vec, err := NewHistogramVec(reg, name, buckets, labels...)
+	if m := s.schema.lookup(name); m != nil && len(m.Buckets) > 0 {
+		buckets = m.Buckets
+	}
+
+	vec, err := newHistogramVec(reg, name, s.help(name), buckets, labels...)
 	s.histograms[name] = vec
 	return vec, err
 }
+
+func (s *Store) getInfo(reg prometheus.Registerer, name string, labels ...string) (*InfoVec, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.schema.check(infoName(name), InfoType, labels); err != nil {
+		return nil, err
+	}
+
+	if vec, ok := s.infos[infoName(name)]; ok {
+		return vec, nil
+	}
+
+	vec, err := NewInfoVec(reg, name, labels...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.infos[vec.Name()] = vec
+	return vec, nil
+}
+
+func (s *Store) getStateSet(reg prometheus.Registerer, name string, states []string, labels ...string) (*StateSet, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.schema.check(name, StateSetType, labels); err != nil {
+		return nil, err
+	}
+
+	if set, ok := s.statesets[name]; ok {
+		return set, nil
+	}
+
+	set, err := NewStateSet(reg, name, states, labels...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.statesets[name] = set
+	return set, nil
+}
+
+// help returns the declared help string of the metric, or the default help
+// string if it isn't declared.
+func (s *Store) help(name string) string {
+	if m := s.schema.lookup(name); m != nil {
+		return m.help()
+	}
+	return DefaultHelpString
+}
+
+// declare records the function that removes the collector created for a
+// declared metric, which is called if the metric is registered with a
+// callback before the collector is used.
+func (s *Store) declare(name string, remove func()) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.declared[name] = remove
+}
+
+// admit enforces the declared cardinality limit of the metric for the label
+// values.
+func (s *Store) admit(name string, lv []string) error {
+	return s.schema.admit(name, lv)
+}
+
+// openMetricsTypes returns the types of the families that can't be expressed
+// by the prometheus client model, keyed by the family name.  They are used to
+// expose the native OpenMetrics types.
+func (s *Store) openMetricsTypes() map[string]MetricType {
+	s.Lock()
+	defer s.Unlock()
+
+	types := make(map[string]MetricType, len(s.infos)+len(s.statesets))
+	for name := range s.infos {
+		types[name] = InfoType
+	}
+	for name := range s.statesets {
+		types[name] = StateSetType
+	}
+	return types
+}
+
+// addFunc tracks a callback based collector.  Unlike the other collectors the
+// callback can't be shared, so registering the same name a second time
+// returns ErrAlreadyRegistered.  The collector is created with the declared
+// help string of the metric.
+func (s *Store) addFunc(name string, mtype MetricType, labels []string, create func(help string) (MetricVec, error)) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.schema.check(name, mtype, labels); err != nil {
+		return err
+	}
+
+	if _, ok := s.funcs[name]; ok {
+		return ErrAlreadyRegistered
+	}
+
+	if remove, ok := s.declared[name]; ok {
+		remove()
+		delete(s.declared, name)
+	}
+
+	vec, err := create(s.help(name))
+	if err != nil {
+		return err
+	}
+
+	s.funcs[name] = vec
+	return nil
+}
+
+// descriptor describes a collector in the store.
+type descriptor struct {
+	name      string
+	mtype     MetricType
+	labels    []string
+	quantiles []float64
+}
+
+// descriptors returns the descriptions of the counters, gauges, histograms,
+// summaries and callback collectors in the store, sorted by name.
+func (s *Store) descriptors() []descriptor {
+	s.Lock()
+	defer s.Unlock()
+
+	descs := make([]descriptor, 0, len(s.counters)+len(s.gauges)+len(s.histograms)+len(s.summaries)+len(s.funcs))
+	for _, vec := range s.counters {
+		if vec != nil {
+			descs = append(descs, descriptor{name: vec.name, mtype: CounterType, labels: vec.labels})
+		}
+	}
+	for _, vec := range s.gauges {
+		if vec != nil {
+			descs = append(descs, descriptor{name: vec.name, mtype: GaugeType, labels: vec.labels})
+		}
+	}
+	for _, vec := range s.histograms {
+		if vec != nil {
+			descs = append(descs, descriptor{name: vec.name, mtype: HistogramType, labels: vec.labels})
+		}
+	}
+	for _, vec := range s.summaries {
+		if vec != nil {
+			quantiles := make([]float64, 0, len(vec.objectives))
+			for q := range vec.objectives {
+				quantiles = append(quantiles, q)
+			}
+			sort.Float64s(quantiles)
+			descs = append(descs, descriptor{name: vec.name, mtype: SummaryType, labels: vec.labels, quantiles: quantiles})
+		}
+	}
+	for _, vec := range s.funcs {
+		var labels []string
+		switch v := vec.(type) {
+		case *GaugeFuncVec:
+			labels = v.labels
+		case *CounterFuncVec:
+			labels = v.labels
+		case nil:
+			continue
+		}
+		descs = append(descs, descriptor{name: vec.Name(), mtype: vec.Type(), labels: labels})
+	}
+
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].name < descs[j].name
+	})
+	return descs
+}
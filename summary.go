@@ -32,14 +32,20 @@ var (
 // It bundles a set of summaries used if you want to count the same thing
 // partitioned by various dimensions.
 type SummaryVec struct {
-	name string
-	vec  *prometheus.SummaryVec
+	name       string
+	vec        *prometheus.SummaryVec
+	labels     []string
+	objectives map[float64]float64
 }
 
 func NewSummaryVec(registerer prometheus.Registerer, name string, opts SummaryOpts, labels ...string) (*SummaryVec, error) {
+	return newSummaryVec(registerer, name, DefaultHelpString, opts, labels...)
+}
+
+func newSummaryVec(registerer prometheus.Registerer, name string, help string, opts SummaryOpts, labels ...string) (*SummaryVec, error) {
 	summary := prometheus.NewSummaryVec(prometheus.SummaryOpts{
 		Name:       name,
-		Help:       DefaultHelpString,
+		Help:       help,
 		Objectives: opts.Objectives,
 		MaxAge:     opts.MaxAge,
 		AgeBuckets: opts.AgeBuckets,
@@ -50,8 +56,10 @@ func NewSummaryVec(registerer prometheus.Registerer, name string, opts SummaryOp
 	}
 
 	return &SummaryVec{
-		name: name,
-		vec:  summary,
+		name:       name,
+		vec:        summary,
+		labels:     labels,
+		objectives: opts.Objectives,
 	}, nil
 }
 
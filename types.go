@@ -38,6 +38,12 @@ const (
 	// HistogramType represents an strata wrapper around the prometheus HistogramVec
 	// type.
 	HistogramType MetricType = "histogram"
+	// InfoType represents an strata info metric.  Info metrics are exposed as
+	// gauges unless OpenMetrics is negotiated.
+	InfoType MetricType = "info"
+	// StateSetType represents an strata stateset metric.  StateSets are exposed
+	// as gauges unless OpenMetrics is negotiated.
+	StateSetType MetricType = "stateset"
 	// Defines the metrics help string.  This is currently not settable.
 	DefaultHelpString string = "created automagically by strata"
 )
@@ -20,6 +20,8 @@
 package strata
 
 import (
+	"sort"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -35,11 +37,12 @@ func Register(reg prometheus.Registerer, metric prometheus.Collector) error {
 	return nil
 }
 
-// SlicePairsToMap copies key value pairs to a map.
+// SlicePairsToMap copies key value pairs to a map.  A trailing key without a
+// value is ignored and later pairs replace earlier pairs with the same key.
+// Use NewWithError to validate the constant labels of the metrics.
 func SlicePairsToMap(pairs []string) map[string]string {
-	// TODO: this is too brittle. Fix me.
-	m := make(map[string]string)
-	for i := 0; i < len(pairs); i += 2 {
+	m := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
 		m[pairs[i]] = pairs[i+1]
 	}
 	return m
@@ -51,3 +54,24 @@ func prefixedName(prefix, name string, sep rune) string {
 	}
 	return prefix + string(sep) + name
 }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeLabels returns a new map containing the labels from all of the maps.
+// Later maps take precedence.
+func mergeLabels(maps ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}